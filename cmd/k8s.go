@@ -31,7 +31,7 @@ var (
 				}
 
 				// validate them
-				if err := step.ExecuteValidate(steps); err != nil {
+				if err := step.ExecuteValidate(steps, executeOptions(p, "k8s")...); err != nil {
 					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 					os.Exit(1)
 				}
@@ -55,7 +55,7 @@ var (
 				}
 
 				// plan them
-				if err := step.ExecutePlan(steps); err != nil {
+				if err := step.ExecutePlan(steps, executeOptions(p, "k8s")...); err != nil {
 					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 					os.Exit(1)
 				}
@@ -79,7 +79,7 @@ var (
 				}
 
 				// apply them
-				if err := step.ExecuteApply(steps); err != nil {
+				if err := step.ExecuteApply(steps, executeOptions(p, "k8s")...); err != nil {
 					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 					os.Exit(1)
 				}
@@ -103,7 +103,7 @@ var (
 				}
 
 				// destroy them
-				if err := step.ExecuteDestroy(steps); err != nil {
+				if err := step.ExecuteDestroy(steps, executeOptions(p, "k8s")...); err != nil {
 					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 					os.Exit(1)
 				}
@@ -126,6 +126,11 @@ func k8s() []step.Platform {
 		os.Exit(1)
 	}
 
+	if err := loadSecrets(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load secrets: %s\n", err.Error())
+		os.Exit(1)
+	}
+
 	platforms := []step.Platform{}
 
 	platform := step.Platform{}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/step"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	kubeconfigCmd = &cobra.Command{
+		Use:   "kubeconfig",
+		Short: "Manage access to the platform's k8s clusters",
+		Long:  "Manage access to the platform's k8s clusters.",
+	}
+
+	exportKubeconfigCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export kubeconfig",
+		Long:  "Merge the kubeconfig of each cluster into the user's kubeconfig under the context <provider>-<name>-<env>-<region>.",
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, p := range kubeconfig() {
+				// export them
+				if err := p.ExportKubeconfig(viper.GetBool("use-context")); err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+					os.Exit(1)
+				}
+			}
+
+			fmt.Println("export succeeded")
+		},
+	}
+)
+
+func kubeconfig() []step.Platform {
+	if len(viper.Get("config-file").(string)) == 0 {
+		fmt.Fprintf(os.Stderr, "no platforms defined in the config file %s\n", viper.Get("config-file"))
+		os.Exit(1)
+	}
+
+	configBytes, err := os.ReadFile(viper.Get("config-file").(string))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config file: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if err := loadSecrets(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load secrets: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	platforms := []step.Platform{}
+
+	platform := step.Platform{}
+
+	// TODO: figure out how to unmarshal array of platforms from yaml
+	if err := yaml.Unmarshal(configBytes, &platform); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to unmarshal config file: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	platforms = append(platforms, platform)
+
+	return platforms
+}
+
+func init() {
+	kubeconfigCmd.AddCommand(exportKubeconfigCmd)
+
+	exportKubeconfigCmd.Flags().BoolP("use-context", "", false, "Make the exported context the current one")
+	viper.BindPFlag("use-context", exportKubeconfigCmd.Flags().Lookup("use-context"))
+
+	rootCmd.AddCommand(kubeconfigCmd)
+}
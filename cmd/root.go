@@ -1,11 +1,22 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/secret"
+	"github.com/w-h-a/cli/internal/secret/env"
+	"github.com/w-h-a/cli/internal/secret/file"
+	"github.com/w-h-a/cli/internal/secret/sops"
+	"github.com/w-h-a/cli/internal/secret/vault"
+	"github.com/w-h-a/cli/internal/step"
+	"github.com/w-h-a/cli/internal/task"
 )
 
 var rootCmd = &cobra.Command{
@@ -21,12 +32,31 @@ func viperConfig() {
 	viper.SetDefault("aws-dynamodb-table", "wha-infra-terraform-lock")
 	viper.SetDefault("base-source", "https://github.com/w-h-a")
 	viper.SetDefault("node-port", "0")
+	viper.SetDefault("journal-dir", "/tmp/cli-journal")
+	viper.SetDefault("secret-vars", []string{
+		"do_token",
+		"secret",
+		"payment_key",
+		"aws_secret_access_key",
+	})
+	viper.SetDefault("retry-max-attempts", 1)
+	viper.SetDefault("retry-backoff", "10s")
+	viper.SetDefault("retry-patterns", []string{
+		"429",
+		"connection reset",
+		"Error acquiring the state lock",
+	})
 }
 
 func init() {
 	cobra.OnInitialize(viperConfig)
 
-	rootCmd.PersistentFlags().StringP("do-token", "d", "", "DO provider token")
+	secret.Register(env.NewResolver())
+	secret.Register(file.NewResolver())
+	secret.Register(sops.NewResolver())
+	secret.Register(vault.NewResolver())
+
+	rootCmd.PersistentFlags().StringP("do-token", "d", "", "DO provider token or a secret reference")
 	viper.BindPFlag("do-token", rootCmd.PersistentFlags().Lookup("do-token"))
 
 	rootCmd.PersistentFlags().StringP("aws-s3-bucket", "b", "", "AWS S3 bucket name")
@@ -40,9 +70,102 @@ func init() {
 
 	rootCmd.PersistentFlags().StringP("config-file", "c", "", "Path to config file")
 	viper.BindPFlag("config-file", rootCmd.PersistentFlags().Lookup("config-file"))
+
+	rootCmd.PersistentFlags().DurationP("timeout", "", 0, "Timeout for each task lifecycle call (0 for none)")
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+
+	rootCmd.PersistentFlags().IntP("retry-max-attempts", "", 0, "Maximum attempts for a failed task lifecycle call")
+	viper.BindPFlag("retry-max-attempts", rootCmd.PersistentFlags().Lookup("retry-max-attempts"))
+
+	rootCmd.PersistentFlags().DurationP("retry-backoff", "", 0, "Wait before the first retry, doubled on each further retry")
+	viper.BindPFlag("retry-backoff", rootCmd.PersistentFlags().Lookup("retry-backoff"))
+
+	rootCmd.PersistentFlags().StringSliceP("retry-patterns", "", nil, "Output patterns that mark a failure as retryable")
+	viper.BindPFlag("retry-patterns", rootCmd.PersistentFlags().Lookup("retry-patterns"))
+
+	rootCmd.PersistentFlags().BoolP("keep-going", "k", false, "Keep running independent tasks after a task fails")
+	viper.BindPFlag("keep-going", rootCmd.PersistentFlags().Lookup("keep-going"))
+
+	rootCmd.PersistentFlags().StringP("journal-dir", "", "", "Directory of the run journals")
+	viper.BindPFlag("journal-dir", rootCmd.PersistentFlags().Lookup("journal-dir"))
+
+	rootCmd.PersistentFlags().StringSliceP("secret-vars", "", nil, "Terraform vars whose values are masked in output")
+	viper.BindPFlag("secret-vars", rootCmd.PersistentFlags().Lookup("secret-vars"))
+
+	rootCmd.PersistentFlags().StringSliceP("values", "", nil, "SOPS encrypted values files whose keys are flag names")
+	viper.BindPFlag("values", rootCmd.PersistentFlags().Lookup("values"))
+
+	rootCmd.PersistentFlags().StringP("age-key-file", "", "", "Age key file used to decrypt SOPS files")
+	viper.BindPFlag("age-key-file", rootCmd.PersistentFlags().Lookup("age-key-file"))
+
+	rootCmd.PersistentFlags().StringP("vault-addr", "", "", "Vault address (defaults to $VAULT_ADDR)")
+	viper.BindPFlag("vault-addr", rootCmd.PersistentFlags().Lookup("vault-addr"))
+	viper.BindEnv("vault-addr", "VAULT_ADDR")
+
+	rootCmd.PersistentFlags().StringP("vault-token", "", "", "Vault token (defaults to $VAULT_TOKEN)")
+	viper.BindPFlag("vault-token", rootCmd.PersistentFlags().Lookup("vault-token"))
+	viper.BindEnv("vault-token", "VAULT_TOKEN")
+
+	rootCmd.PersistentFlags().StringP("vault-role-id", "", "", "Vault AppRole role id (defaults to $VAULT_ROLE_ID)")
+	viper.BindPFlag("vault-role-id", rootCmd.PersistentFlags().Lookup("vault-role-id"))
+	viper.BindEnv("vault-role-id", "VAULT_ROLE_ID")
+
+	rootCmd.PersistentFlags().StringP("vault-secret-id", "", "", "Vault AppRole secret id (defaults to $VAULT_SECRET_ID)")
+	viper.BindPFlag("vault-secret-id", rootCmd.PersistentFlags().Lookup("vault-secret-id"))
+	viper.BindEnv("vault-secret-id", "VAULT_SECRET_ID")
+
+	rootCmd.PersistentFlags().StringP("vault-namespace", "", "", "Vault namespace (defaults to $VAULT_NAMESPACE)")
+	viper.BindPFlag("vault-namespace", rootCmd.PersistentFlags().Lookup("vault-namespace"))
+	viper.BindEnv("vault-namespace", "VAULT_NAMESPACE")
+
+	rootCmd.PersistentFlags().StringArrayP("var", "", nil, "Extra terraform var as key=value, lists and maps as json (repeatable)")
+	viper.BindPFlag("var", rootCmd.PersistentFlags().Lookup("var"))
+
+	rootCmd.PersistentFlags().StringArrayP("var-file", "", nil, "Yaml or json file of extra terraform vars (repeatable)")
+	viper.BindPFlag("var-file", rootCmd.PersistentFlags().Lookup("var-file"))
+
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Show more detail, e.g. the vars passed to terraform")
+	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+
+	rootCmd.PersistentFlags().BoolP("resume", "", false, "On apply, skip tasks applied successfully in the last run with identical inputs")
+	viper.BindPFlag("resume", rootCmd.PersistentFlags().Lookup("resume"))
+}
+
+// loadSecrets merges the decrypted values files under the flags and then
+// resolves every secret reference.
+func loadSecrets() error {
+	for _, path := range viper.GetStringSlice("values") {
+		values, err := sops.Values(path)
+		if err != nil {
+			return err
+		}
+
+		if err := viper.MergeConfigMap(values); err != nil {
+			return fmt.Errorf("failed to merge values from %s: %v", path, err)
+		}
+	}
+
+	return secret.ResolveViper()
+}
+
+func executeOptions(p step.Platform, command string) []step.ExecuteOption {
+	journal := filepath.Join(viper.GetString("journal-dir"), fmt.Sprintf("%s-%s-%s.jsonl", p.Name, p.Env, command))
+
+	return []step.ExecuteOption{
+		step.ExecuteWithKeepGoing(viper.GetBool("keep-going")),
+		step.ExecuteWithJournal(journal),
+		step.ExecuteWithResume(viper.GetBool("resume")),
+	}
 }
 
 func Execute() {
+	// an interrupt cancels the running tasks, which pass it on to their
+	// commands and wait for them to clean up
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	task.SetBaseContext(ctx)
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -18,22 +20,27 @@ var (
 	}
 
 	validateServiceCmd = &cobra.Command{
-		Use:   "validate",
+		Use:   "validate [name]",
 		Short: "Validate service",
-		Long:  "Validate service.",
+		Long:  "Validate the service declared in the config with the given name, every declared service with --all, or the service given with flags.",
+		Args:  cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			for _, p := range service() {
-				// get the steps
-				steps, err := p.ServiceSteps()
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-					os.Exit(1)
-				}
-
-				// validate them
-				if err := step.ExecuteValidate(steps); err != nil {
-					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-					os.Exit(1)
+				for _, name := range serviceNames(p, args) {
+					useService(&p, name)
+
+					// get the steps
+					steps, err := p.ServiceSteps()
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+						os.Exit(1)
+					}
+
+					// validate them
+					if err := step.ExecuteValidate(steps, executeOptions(p, serviceCommand(name))...); err != nil {
+						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+						os.Exit(1)
+					}
 				}
 			}
 
@@ -42,22 +49,27 @@ var (
 	}
 
 	planServiceCmd = &cobra.Command{
-		Use:   "plan",
+		Use:   "plan [name]",
 		Short: "Plan service",
-		Long:  "Plan service.",
+		Long:  "Plan the service declared in the config with the given name, every declared service with --all, or the service given with flags.",
+		Args:  cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			for _, p := range service() {
-				// get the steps
-				steps, err := p.ServiceSteps()
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-					os.Exit(1)
-				}
-
-				// plan them
-				if err := step.ExecutePlan(steps); err != nil {
-					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-					os.Exit(1)
+				for _, name := range serviceNames(p, args) {
+					useService(&p, name)
+
+					// get the steps
+					steps, err := p.ServiceSteps()
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+						os.Exit(1)
+					}
+
+					// plan them
+					if err := step.ExecutePlan(steps, executeOptions(p, serviceCommand(name))...); err != nil {
+						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+						os.Exit(1)
+					}
 				}
 			}
 
@@ -66,35 +78,121 @@ var (
 	}
 
 	applyServiceCmd = &cobra.Command{
-		Use:   "apply",
+		Use:   "apply [name]",
 		Short: "Apply service",
-		Long:  "Apply service.",
+		Long:  "Apply the service declared in the config with the given name, every declared service with --all, or the service given with flags.",
+		Args:  cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			for _, p := range service() {
-				// get the steps
-				steps, err := p.ServiceSteps()
+				for _, name := range serviceNames(p, args) {
+					useService(&p, name)
+
+					// get the steps
+					steps, err := p.ServiceSteps()
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+						os.Exit(1)
+					}
+
+					// apply them
+					if err := step.ExecuteApply(steps, executeOptions(p, serviceCommand(name))...); err != nil {
+						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+						os.Exit(1)
+					}
+				}
+			}
+
+			fmt.Println("apply succeeded")
+		},
+	}
+
+	destroyServiceCmd = &cobra.Command{
+		Use:   "destroy [name]",
+		Short: "Destroy service",
+		Long:  "Destroy the service declared in the config with the given name, every declared service with --all, or the service given with flags.",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, p := range service() {
+				for _, name := range serviceNames(p, args) {
+					useService(&p, name)
+
+					// get the steps
+					steps, err := p.ServiceSteps()
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+						os.Exit(1)
+					}
+
+					// destroy them
+					if err := step.ExecuteDestroy(steps, executeOptions(p, serviceCommand(name))...); err != nil {
+						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+						os.Exit(1)
+					}
+				}
+			}
+
+			fmt.Println("destroy succeeded")
+		},
+	}
+
+	historyServiceCmd = &cobra.Command{
+		Use:   "history <name>",
+		Short: "Show the deployments of a service",
+		Long:  "Show the recorded deployments of a service in every region, oldest first.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+			fmt.Fprintln(w, "DEPLOYED\tPLATFORM\tENV\tREGION\tVERSION\tIMAGE\tMODULE\tUSER")
+
+			for _, p := range service() {
+				useService(&p, args[0])
+
+				// get the history
+				entries, err := p.ServiceHistory()
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 					os.Exit(1)
 				}
 
-				// apply them
-				if err := step.ExecuteApply(steps); err != nil {
-					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-					os.Exit(1)
+				for _, e := range entries {
+					module := e.Module
+					if len(module) > 7 {
+						module = module[:7]
+					}
+
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Timestamp.Local().Format(time.RFC3339), e.Platform, e.Env, e.Region, e.Version, e.Image, module, e.User)
 				}
 			}
 
-			fmt.Println("apply succeeded")
+			w.Flush()
 		},
 	}
 
-	destroyServiceCmd = &cobra.Command{
-		Use:   "destroy",
-		Short: "Destroy service",
-		Long:  "Destroy service.",
+	rollbackServiceCmd = &cobra.Command{
+		Use:   "rollback <name>",
+		Short: "Roll back service",
+		Long:  "Apply a service again with the version it ran before, or with the version given with --to.",
+		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			for _, p := range service() {
+				useService(&p, args[0])
+
+				// find the version to go back to
+				target, err := p.RollbackTarget(viper.GetString("to"))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+					os.Exit(1)
+				}
+
+				viper.Set("service-version", target.Version)
+
+				if len(viper.GetString("service-image")) == 0 {
+					viper.Set("service-image", target.Image)
+				}
+
+				fmt.Printf("rolling back %s to %s deployed %s\n", args[0], target.Version, target.Timestamp.Local().Format(time.RFC3339))
+
 				// get the steps
 				steps, err := p.ServiceSteps()
 				if err != nil {
@@ -102,14 +200,14 @@ var (
 					os.Exit(1)
 				}
 
-				// destroy them
-				if err := step.ExecuteDestroy(steps); err != nil {
+				// apply them
+				if err := step.ExecuteApply(steps, executeOptions(p, serviceCommand(args[0]))...); err != nil {
 					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 					os.Exit(1)
 				}
 			}
 
-			fmt.Println("destroy succeeded")
+			fmt.Println("rollback succeeded")
 		},
 	}
 )
@@ -126,6 +224,11 @@ func service() []step.Platform {
 		os.Exit(1)
 	}
 
+	if err := loadSecrets(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load secrets: %s\n", err.Error())
+		os.Exit(1)
+	}
+
 	platforms := []step.Platform{}
 
 	platform := step.Platform{}
@@ -141,11 +244,84 @@ func service() []step.Platform {
 	return platforms
 }
 
+// serviceNames returns the services to run for, every declared one with
+// --all, else the one given as an argument. An empty name stands for the
+// service given with flags.
+func serviceNames(p step.Platform, args []string) []string {
+	if !viper.GetBool("all") {
+		if len(args) == 0 {
+			return []string{""}
+		}
+
+		return args
+	}
+
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "give either a service name or --all\n")
+		os.Exit(1)
+	}
+
+	if len(p.Services) == 0 {
+		fmt.Fprintf(os.Stderr, "no services declared for platform %s\n", p.Name)
+		os.Exit(1)
+	}
+
+	names := []string{}
+
+	for _, s := range p.Services {
+		names = append(names, s.Name)
+	}
+
+	return names
+}
+
+// useService points the service flags at the named service. Without
+// declared services it is the one given with the other flags.
+func useService(p *step.Platform, name string) {
+	if len(name) == 0 {
+		return
+	}
+
+	if len(p.Services) == 0 {
+		viper.Set("service-name", name)
+		return
+	}
+
+	s, ok := p.Service(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown service %s\n", name)
+		os.Exit(1)
+	}
+
+	if err := p.UseService(s); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// serviceCommand names the journal of a run, one per declared service so
+// each can be resumed on its own.
+func serviceCommand(name string) string {
+	if len(name) == 0 {
+		return "service"
+	}
+
+	return fmt.Sprintf("service-%s", name)
+}
+
 func init() {
 	serviceCmd.AddCommand(validateServiceCmd)
 	serviceCmd.AddCommand(planServiceCmd)
 	serviceCmd.AddCommand(applyServiceCmd)
 	serviceCmd.AddCommand(destroyServiceCmd)
+	serviceCmd.AddCommand(historyServiceCmd)
+	serviceCmd.AddCommand(rollbackServiceCmd)
+
+	rollbackServiceCmd.Flags().StringP("to", "", "", "The version to roll back to, by default the one before the current")
+	viper.BindPFlag("to", rollbackServiceCmd.Flags().Lookup("to"))
+
+	serviceCmd.PersistentFlags().BoolP("all", "", false, "Run for every service declared in the config")
+	viper.BindPFlag("all", serviceCmd.PersistentFlags().Lookup("all"))
 
 	serviceCmd.PersistentFlags().StringP("resource-namespace", "", "", "The namespace of shared resources")
 	viper.BindPFlag("resource-namespace", serviceCmd.PersistentFlags().Lookup("resource-namespace"))
@@ -180,10 +356,10 @@ func init() {
 	serviceCmd.PersistentFlags().StringP("admin", "", "", "The admin id")
 	viper.BindPFlag("admin", serviceCmd.PersistentFlags().Lookup("admin"))
 
-	serviceCmd.PersistentFlags().StringP("secret", "", "", "The admin secret")
+	serviceCmd.PersistentFlags().StringP("secret", "", "", "The admin secret or a secret reference")
 	viper.BindPFlag("secret", serviceCmd.PersistentFlags().Lookup("secret"))
 
-	serviceCmd.PersistentFlags().StringP("payment-key", "", "", "The payment secret")
+	serviceCmd.PersistentFlags().StringP("payment-key", "", "", "The payment secret or a secret reference")
 	viper.BindPFlag("payment-key", serviceCmd.PersistentFlags().Lookup("payment-key"))
 
 	serviceCmd.PersistentFlags().StringP("enable-tls", "", "false", "Enable TLS")
@@ -195,11 +371,17 @@ func init() {
 	serviceCmd.PersistentFlags().StringP("hosts", "", "", "Comma separated list of hosts")
 	viper.BindPFlag("hosts", serviceCmd.PersistentFlags().Lookup("hosts"))
 
-	serviceCmd.PersistentFlags().StringP("aws-access-key", "", "", "AWS access key")
+	serviceCmd.PersistentFlags().StringP("aws-access-key", "", "", "AWS access key or a secret reference")
 	viper.BindPFlag("aws-access-key", serviceCmd.PersistentFlags().Lookup("aws-access-key"))
 
-	serviceCmd.PersistentFlags().StringP("aws-secret-access-key", "", "", "AWS secret access key")
+	serviceCmd.PersistentFlags().StringP("aws-secret-access-key", "", "", "AWS secret access key or a secret reference")
 	viper.BindPFlag("aws-secret-access-key", serviceCmd.PersistentFlags().Lookup("aws-secret-access-key"))
 
+	serviceCmd.PersistentFlags().BoolP("no-rollback", "", false, "Keep a new version that never got ready instead of rolling back")
+	viper.BindPFlag("no-rollback", serviceCmd.PersistentFlags().Lookup("no-rollback"))
+
+	serviceCmd.PersistentFlags().StringP("strategy", "", "", "Roll out a new version next to the running one with canary or bluegreen instead of updating it in place")
+	viper.BindPFlag("strategy", serviceCmd.PersistentFlags().Lookup("strategy"))
+
 	rootCmd.AddCommand(serviceCmd)
 }
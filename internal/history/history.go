@@ -0,0 +1,172 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/viper"
+)
+
+// Entry records one successful deployment of a service.
+type Entry struct {
+	Platform  string    `json:"platform"`
+	Env       string    `json:"env"`
+	Region    string    `json:"region"`
+	Service   string    `json:"service"`
+	Version   string    `json:"version"`
+	Image     string    `json:"image"`
+	Module    string    `json:"module"`
+	User      string    `json:"user"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Key is the prefix the history of a deployment lives under in the state
+// store, one object per entry so concurrent deployments never overwrite
+// each other's.
+func Key(name string) string {
+	return fmt.Sprintf("history/%s/", name)
+}
+
+// List returns the entries under key, oldest first. A deployment without
+// history has none.
+func List(ctx context.Context, key string) ([]Entry, error) {
+	stateStore := viper.GetString("state-store")
+
+	switch stateStore {
+	case "aws":
+		return listAWS(ctx, key)
+	default:
+		return nil, fmt.Errorf("%s is not a supported history backend", stateStore)
+	}
+}
+
+// Append adds e to the entries under key.
+func Append(ctx context.Context, key string, e Entry) error {
+	stateStore := viper.GetString("state-store")
+
+	switch stateStore {
+	case "aws":
+		return appendAWS(ctx, key, e)
+	default:
+		return fmt.Errorf("%s is not a supported history backend", stateStore)
+	}
+}
+
+func listAWS(ctx context.Context, key string) ([]Entry, error) {
+	s3Client, err := s3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := viper.GetString("aws-s3-bucket")
+
+	keys := []string{}
+
+	if err := s3Client.ListObjectsV2PagesWithContext(
+		ctx,
+		&s3.ListObjectsV2Input{
+			Bucket: aws.String(bucket),
+			Prefix: aws.String(key),
+		},
+		func(page *s3.ListObjectsV2Output, last bool) bool {
+			for _, o := range page.Contents {
+				keys = append(keys, aws.StringValue(o.Key))
+			}
+			return true
+		},
+	); err != nil {
+		return nil, fmt.Errorf("failed to list history %s: %v", key, err)
+	}
+
+	entries := []Entry{}
+
+	for _, k := range keys {
+		read, err := s3Client.GetObjectWithContext(
+			ctx,
+			&s3.GetObjectInput{
+				Key:    aws.String(k),
+				Bucket: aws.String(bucket),
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history %s: %v", k, err)
+		}
+
+		bs, err := io.ReadAll(read.Body)
+		read.Body.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history %s: %v", k, err)
+		}
+
+		e := Entry{}
+
+		if err := json.Unmarshal(bs, &e); err != nil {
+			return nil, fmt.Errorf("failed to parse history %s: %v", k, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	return entries, nil
+}
+
+func appendAWS(ctx context.Context, key string, e Entry) error {
+	bs, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	// the time keeps the keys in order and the suffix apart
+	entryKey := fmt.Sprintf("%s%s-%x.json", key, e.Timestamp.UTC().Format("20060102T150405.000000000Z"), suffix)
+
+	s3Client, err := s3Client()
+	if err != nil {
+		return err
+	}
+
+	if _, err := s3Client.PutObjectWithContext(
+		ctx,
+		&s3.PutObjectInput{
+			Key:         aws.String(entryKey),
+			Bucket:      aws.String(viper.GetString("aws-s3-bucket")),
+			Body:        bytes.NewReader(bs),
+			ContentType: aws.String("application/json"),
+		},
+	); err != nil {
+		return fmt.Errorf("failed to write history %s: %v", entryKey, err)
+	}
+
+	return nil
+}
+
+func s3Client() (*s3.S3, error) {
+	config := &aws.Config{
+		Region: aws.String(viper.GetString("aws-region")),
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate an aws session: %v", err)
+	}
+
+	return s3.New(sess), nil
+}
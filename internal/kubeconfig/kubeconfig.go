@@ -0,0 +1,273 @@
+package kubeconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type Cluster struct {
+	Name    string        `yaml:"name"`
+	Cluster yaml.MapSlice `yaml:"cluster"`
+}
+
+type User struct {
+	Name string        `yaml:"name"`
+	User yaml.MapSlice `yaml:"user"`
+}
+
+type Context struct {
+	Name    string        `yaml:"name"`
+	Context yaml.MapSlice `yaml:"context"`
+}
+
+type Config struct {
+	APIVersion     string                 `yaml:"apiVersion"`
+	Kind           string                 `yaml:"kind"`
+	Clusters       []Cluster              `yaml:"clusters"`
+	Users          []User                 `yaml:"users"`
+	Contexts       []Context              `yaml:"contexts"`
+	CurrentContext string                 `yaml:"current-context"`
+	Rest           map[string]interface{} `yaml:",inline"`
+}
+
+// KindContext is the context kind writes for a cluster.
+func KindContext(clusterName string) string {
+	return "kind-" + clusterName
+}
+
+// Paths returns the user's kubeconfig files the way kubectl finds them:
+// every entry of $KUBECONFIG, or ~/.kube/config.
+func Paths() []string {
+	paths := []string{}
+
+	for _, path := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if len(path) != 0 {
+			paths = append(paths, Expand(path))
+		}
+	}
+
+	if len(paths) == 0 {
+		paths = append(paths, Expand(filepath.Join("~", ".kube", "config")))
+	}
+
+	return paths
+}
+
+// Path returns the kubeconfig file that defines context, or the first of
+// Paths when none does (or context is empty).
+func Path(context string) string {
+	paths := Paths()
+
+	if len(context) == 0 {
+		return paths[0]
+	}
+
+	for _, path := range paths {
+		config, err := Load(path)
+		if err != nil {
+			continue
+		}
+
+		for _, c := range config.Contexts {
+			if c.Name == context {
+				return path
+			}
+		}
+	}
+
+	return paths[0]
+}
+
+// Expand resolves a leading ~ and environment variables, which the process
+// environment never does on its own.
+func Expand(path string) string {
+	path = os.ExpandEnv(path)
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+
+	return path
+}
+
+func Load(path string) (*Config, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+
+	if err := yaml.Unmarshal(bs, config); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig %s: %v", path, err)
+	}
+
+	return config, nil
+}
+
+// Merge copies the current context of the kubeconfig at src, with its
+// cluster and user, into the kubeconfig at dst, naming all three context.
+// Entries of dst with that name are replaced and everything else is kept.
+func Merge(src, dst, context string, use bool) error {
+	from, err := Load(src)
+	if err != nil {
+		return err
+	}
+
+	current := from.CurrentContext
+	if len(current) == 0 && len(from.Contexts) != 0 {
+		current = from.Contexts[0].Name
+	}
+
+	var ref yaml.MapSlice
+
+	for _, c := range from.Contexts {
+		if c.Name == current {
+			ref = c.Context
+		}
+	}
+
+	if ref == nil {
+		return fmt.Errorf("kubeconfig %s has no context %q", src, current)
+	}
+
+	to, err := Load(dst)
+	if os.IsNotExist(err) {
+		to = &Config{APIVersion: "v1", Kind: "Config"}
+	} else if err != nil {
+		return err
+	}
+
+	for _, c := range from.Clusters {
+		if c.Name == value(ref, "cluster") {
+			to.Clusters = append(removeCluster(to.Clusters, context), Cluster{Name: context, Cluster: c.Cluster})
+		}
+	}
+
+	for _, u := range from.Users {
+		if u.Name == value(ref, "user") {
+			to.Users = append(removeUser(to.Users, context), User{Name: context, User: u.User})
+		}
+	}
+
+	// the namespace, extensions and whatever else the context has come along
+	to.Contexts = append(removeContext(to.Contexts, context), Context{
+		Name:    context,
+		Context: set(set(ref, "cluster", context), "user", context),
+	})
+
+	if use || len(to.CurrentContext) == 0 {
+		to.CurrentContext = context
+	}
+
+	return write(dst, to)
+}
+
+// write replaces the file in one step so a failure never leaves half a kubeconfig.
+func write(path string, config *Config) error {
+	bs, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".kubeconfig-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// value returns the string at key, empty if there is none.
+func value(m yaml.MapSlice, key string) string {
+	for _, item := range m {
+		if item.Key == key {
+			s, _ := item.Value.(string)
+			return s
+		}
+	}
+
+	return ""
+}
+
+// set returns a copy of m with key set to v, in place if m has it.
+func set(m yaml.MapSlice, key string, v interface{}) yaml.MapSlice {
+	out := yaml.MapSlice{}
+	found := false
+
+	for _, item := range m {
+		if item.Key == key {
+			item.Value = v
+			found = true
+		}
+
+		out = append(out, item)
+	}
+
+	if !found {
+		out = append(out, yaml.MapItem{Key: key, Value: v})
+	}
+
+	return out
+}
+
+func removeCluster(clusters []Cluster, name string) []Cluster {
+	kept := []Cluster{}
+
+	for _, c := range clusters {
+		if c.Name != name {
+			kept = append(kept, c)
+		}
+	}
+
+	return kept
+}
+
+func removeUser(users []User, name string) []User {
+	kept := []User{}
+
+	for _, u := range users {
+		if u.Name != name {
+			kept = append(kept, u)
+		}
+	}
+
+	return kept
+}
+
+func removeContext(contexts []Context, name string) []Context {
+	kept := []Context{}
+
+	for _, c := range contexts {
+		if c.Name != name {
+			kept = append(kept, c)
+		}
+	}
+
+	return kept
+}
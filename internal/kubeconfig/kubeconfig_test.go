@@ -0,0 +1,156 @@
+package kubeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const srcConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: do-nyc1-platform
+  cluster:
+    server: https://1.2.3.4
+    certificate-authority-data: Y2E=
+users:
+- name: do-nyc1-platform-admin
+  user:
+    token: secret
+contexts:
+- name: do-nyc1-platform
+  context:
+    cluster: do-nyc1-platform
+    user: do-nyc1-platform-admin
+    namespace: shop
+    extensions:
+    - name: digitalocean
+      extension:
+        cluster-id: abc
+current-context: do-nyc1-platform
+`
+
+const dstConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: other
+  cluster:
+    server: https://5.6.7.8
+users:
+- name: other
+  user:
+    token: other
+contexts:
+- name: other
+  context:
+    cluster: other
+    user: other
+    extensions:
+    - name: keep
+      extension:
+        id: other
+current-context: other
+preferences:
+  colors: true
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func context(t *testing.T, config *Config, name string) yaml.MapSlice {
+	t.Helper()
+
+	for _, c := range config.Contexts {
+		if c.Name == name {
+			return c.Context
+		}
+	}
+
+	t.Fatalf("no context %s", name)
+
+	return nil
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	before := filepath.Join(dir, "before")
+
+	writeFile(t, src, srcConfig)
+	writeFile(t, dst, dstConfig)
+	writeFile(t, before, dstConfig)
+
+	if err := Merge(src, dst, "platform-dev-nyc1", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := Load(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.CurrentContext != "platform-dev-nyc1" {
+		t.Errorf("current context is %s", got.CurrentContext)
+	}
+
+	merged := context(t, got, "platform-dev-nyc1")
+
+	if value(merged, "cluster") != "platform-dev-nyc1" || value(merged, "user") != "platform-dev-nyc1" {
+		t.Errorf("merged context refers to %s and %s", value(merged, "cluster"), value(merged, "user"))
+	}
+
+	if value(merged, "namespace") != "shop" {
+		t.Errorf("merged context lost its namespace: %v", merged)
+	}
+
+	// fields the cli does not know about survive the copy and the rewrite
+	for _, test := range []struct {
+		context string
+		from    string
+		was     string
+	}{
+		{"platform-dev-nyc1", src, "do-nyc1-platform"},
+		{"other", before, "other"},
+	} {
+		want := extensions(context(t, mustLoad(t, test.from), test.was))
+
+		if have := extensions(context(t, got, test.context)); want == nil || !reflect.DeepEqual(have, want) {
+			t.Errorf("context %s has extensions %v, want %v", test.context, have, want)
+		}
+	}
+
+	if _, ok := got.Rest["preferences"]; !ok {
+		t.Errorf("top level fields were dropped: %v", got.Rest)
+	}
+}
+
+func mustLoad(t *testing.T, path string) *Config {
+	t.Helper()
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func extensions(m yaml.MapSlice) interface{} {
+	for _, item := range m {
+		if item.Key == "extensions" {
+			return item.Value
+		}
+	}
+
+	return nil
+}
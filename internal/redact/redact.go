@@ -0,0 +1,160 @@
+package redact
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+const Mask = "***"
+
+// String masks every secret in s.
+func String(s string, secrets []string) string {
+	bs := []byte(s)
+
+	bss := [][]byte{}
+	for _, secret := range normalize(secrets) {
+		bss = append(bss, []byte(secret))
+	}
+
+	return string(mask(bs, marks(bs, bss)))
+}
+
+// reader masks secrets in a stream. It holds back just enough of what it
+// has read to catch a secret split across reads or lines.
+type reader struct {
+	in        io.Reader
+	secrets   [][]byte
+	hold      int
+	multiline bool
+	buf       []byte
+	pending   []byte
+	ready     []byte
+	err       error
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.ready) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := r.in.Read(r.buf)
+
+		r.pending = append(r.pending, r.buf[:n]...)
+
+		marked := marks(r.pending, r.secrets)
+
+		if err != nil {
+			r.err = err
+			r.ready = mask(r.pending, marked)
+			r.pending = nil
+			continue
+		}
+
+		// anything before the last hold bytes can no longer start a secret
+		cut := len(r.pending) - r.hold
+
+		// without multiline secrets, nothing can straddle a newline
+		if !r.multiline {
+			if nl := bytes.LastIndexByte(r.pending, '\n'); nl+1 > cut {
+				cut = nl + 1
+			}
+		}
+
+		// hold back the whole of a secret the cut would split, since a secret
+		// overlapping it may still be arriving
+		for cut > 0 && cut < len(marked) && marked[cut-1] && marked[cut] {
+			cut--
+		}
+
+		if cut > 0 {
+			r.ready = mask(r.pending[:cut], marked[:cut])
+			r.pending = append([]byte{}, r.pending[cut:]...)
+		}
+	}
+
+	n := copy(p, r.ready)
+
+	r.ready = r.ready[n:]
+
+	return n, nil
+}
+
+// marks reports for every byte of bs whether it belongs to a secret.
+// Occurrences may overlap or nest.
+func marks(bs []byte, secrets [][]byte) []bool {
+	marked := make([]bool, len(bs))
+
+	for _, secret := range secrets {
+		for off := 0; off < len(bs); {
+			i := bytes.Index(bs[off:], secret)
+			if i < 0 {
+				break
+			}
+
+			for j := off + i; j < off+i+len(secret); j++ {
+				marked[j] = true
+			}
+
+			off += i + 1
+		}
+	}
+
+	return marked
+}
+
+// mask replaces every run of marked bytes with a single Mask.
+func mask(bs []byte, marked []bool) []byte {
+	out := make([]byte, 0, len(bs))
+
+	for i, b := range bs {
+		if !marked[i] {
+			out = append(out, b)
+			continue
+		}
+
+		if i == 0 || !marked[i-1] {
+			out = append(out, Mask...)
+		}
+	}
+
+	return out
+}
+
+// NewReader returns a reader that yields what in yields with every secret
+// replaced by Mask.
+func NewReader(in io.Reader, secrets []string) io.Reader {
+	r := &reader{
+		in:      in,
+		secrets: [][]byte{},
+		buf:     make([]byte, 4096),
+	}
+
+	for _, secret := range normalize(secrets) {
+		r.secrets = append(r.secrets, []byte(secret))
+
+		if len(secret)-1 > r.hold {
+			r.hold = len(secret) - 1
+		}
+
+		if strings.Contains(secret, "\n") {
+			r.multiline = true
+		}
+	}
+
+	return r
+}
+
+// normalize drops empty secrets, which would mask everything.
+func normalize(secrets []string) []string {
+	out := []string{}
+
+	for _, secret := range secrets {
+		if len(secret) != 0 {
+			out = append(out, secret)
+		}
+	}
+
+	return out
+}
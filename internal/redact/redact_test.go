@@ -0,0 +1,163 @@
+package redact
+
+import (
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// chunkReader returns one chunk per Read, so tests control where the
+// stream is split.
+type chunkReader struct {
+	chunks []string
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, c.chunks[0])
+
+	c.chunks[0] = c.chunks[0][n:]
+	if len(c.chunks[0]) == 0 {
+		c.chunks = c.chunks[1:]
+	}
+
+	return n, nil
+}
+
+func readAll(t *testing.T, in io.Reader, secrets []string) string {
+	t.Helper()
+
+	bs, err := io.ReadAll(NewReader(in, secrets))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return string(bs)
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunks  []string
+		secrets []string
+		want    string
+	}{
+		{
+			name:    "secret split across reads",
+			chunks:  []string{"token=sec", "ret123 done\n"},
+			secrets: []string{"secret123"},
+			want:    "token=*** done\n",
+		},
+		{
+			name:    "secret split across many reads",
+			chunks:  []string{"a s", "e", "c", "r", "et b"},
+			secrets: []string{"secret"},
+			want:    "a *** b",
+		},
+		{
+			name:    "secret spanning a newline",
+			chunks:  []string{"key: line1\n", "line2 end\n"},
+			secrets: []string{"line1\nline2"},
+			want:    "key: *** end\n",
+		},
+		{
+			name:    "secret spanning a newline in one read",
+			chunks:  []string{"-----BEGIN-----\nabc\n-----END-----\n"},
+			secrets: []string{"-----BEGIN-----\nabc\n-----END-----"},
+			want:    "***\n",
+		},
+		{
+			name:    "overlapping secrets",
+			chunks:  []string{"xxabcdefyy"},
+			secrets: []string{"abcd", "cdef"},
+			want:    "xx***yy",
+		},
+		{
+			name:    "overlapping secrets split across reads",
+			chunks:  []string{"xxabcd", "efyy"},
+			secrets: []string{"abcd", "cdef"},
+			want:    "xx***yy",
+		},
+		{
+			name:    "nested secrets",
+			chunks:  []string{"password1 pass word1"},
+			secrets: []string{"pass", "password1", "word1"},
+			want:    "*** *** ***",
+		},
+		{
+			name:    "held back tail flushed at eof",
+			chunks:  []string{"the end is sec"},
+			secrets: []string{"secret"},
+			want:    "the end is sec",
+		},
+		{
+			name:    "secret at eof",
+			chunks:  []string{"the end is ", "secret"},
+			secrets: []string{"secret"},
+			want:    "the end is ***",
+		},
+		{
+			name:    "empty secrets ignored",
+			chunks:  []string{"nothing to hide\n"},
+			secrets: []string{"", "hidden"},
+			want:    "nothing to hide\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunks := append([]string{}, test.chunks...)
+
+			if got := readAll(t, &chunkReader{chunks: chunks}, test.secrets); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReaderOneByte(t *testing.T) {
+	in := "user=admin pass=hunter2\nkey=line1\nline2\n"
+	secrets := []string{"hunter2", "line1\nline2"}
+	want := "user=admin pass=***\nkey=***\n"
+
+	for _, test := range []struct {
+		name string
+		in   io.Reader
+	}{
+		{"one byte", iotest.OneByteReader(&chunkReader{chunks: []string{in}})},
+		{"half", iotest.HalfReader(&chunkReader{chunks: []string{in}})},
+		{"data with eof", iotest.DataErrReader(&chunkReader{chunks: []string{in}})},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := readAll(t, test.in, secrets); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		secrets []string
+		want    string
+	}{
+		{"single", "a secret b", []string{"secret"}, "a *** b"},
+		{"overlapping", "abcdef", []string{"abcd", "cdef"}, "***"},
+		{"nested", "password1", []string{"pass", "password1"}, "***"},
+		{"multiline", "x\nl1\nl2\ny", []string{"l1\nl2"}, "x\n***\ny"},
+		{"none", "plain", nil, "plain"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := String(test.in, test.secrets); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
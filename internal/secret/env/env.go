@@ -0,0 +1,27 @@
+package env
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/w-h-a/cli/internal/secret"
+)
+
+type envResolver struct{}
+
+func (e *envResolver) Resolve(ref string) (string, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+
+	return v, nil
+}
+
+func (e *envResolver) String() string {
+	return "env"
+}
+
+func NewResolver() secret.Resolver {
+	return &envResolver{}
+}
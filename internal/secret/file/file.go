@@ -0,0 +1,28 @@
+package file
+
+import (
+	"os"
+	"strings"
+
+	"github.com/w-h-a/cli/internal/secret"
+)
+
+type fileResolver struct{}
+
+func (f *fileResolver) Resolve(ref string) (string, error) {
+	bs, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+
+	// editors and echo leave a trailing newline that is never part of the secret
+	return strings.TrimRight(string(bs), "\r\n"), nil
+}
+
+func (f *fileResolver) String() string {
+	return "file"
+}
+
+func NewResolver() secret.Resolver {
+	return &fileResolver{}
+}
@@ -0,0 +1,100 @@
+package secret
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+type Resolver interface {
+	// Resolve returns the secret a reference points to. The reference
+	// arrives without its scheme, e.g. NAME for env:NAME.
+	Resolve(ref string) (string, error)
+	String() string
+}
+
+var (
+	mtx       sync.RWMutex
+	resolvers = map[string]Resolver{}
+	values    = []string{}
+)
+
+// Register makes r resolve references whose scheme is r.String().
+func Register(r Resolver) {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	resolvers[r.String()] = r
+}
+
+// Add records a secret value obtained elsewhere so it is masked like a
+// resolved one.
+func Add(vs ...string) {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	for _, v := range vs {
+		if len(v) != 0 {
+			values = append(values, v)
+		}
+	}
+}
+
+// Values returns every secret resolved or added so far.
+func Values() []string {
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	return append([]string{}, values...)
+}
+
+// Resolve returns the secret that value refers to, or value itself when it
+// is not a reference to a registered scheme.
+func Resolve(value string) (string, error) {
+	scheme, ref, found := strings.Cut(value, ":")
+	if !found {
+		return value, nil
+	}
+
+	mtx.RLock()
+	r, ok := resolvers[scheme]
+	mtx.RUnlock()
+
+	if !ok {
+		return value, nil
+	}
+
+	s, err := r.Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s secret %s: %v", scheme, ref, err)
+	}
+
+	Add(s)
+
+	return s, nil
+}
+
+// ResolveViper replaces every viper value that is a secret reference with
+// the secret it points to, so vars built from viper keys never see the
+// reference.
+func ResolveViper() error {
+	for _, key := range viper.AllKeys() {
+		value, ok := viper.Get(key).(string)
+		if !ok {
+			continue
+		}
+
+		resolved, err := Resolve(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+
+		if resolved != value {
+			viper.Set(key, resolved)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package sops
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/secret"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	mtx       sync.Mutex
+	decrypted = map[string]map[string]interface{}{}
+)
+
+type sopsResolver struct{}
+
+// Resolve takes a reference of the form path#key, where key is a dot
+// separated path into the decrypted document.
+func (s *sopsResolver) Resolve(ref string) (string, error) {
+	path, key, found := strings.Cut(ref, "#")
+	if !found || len(key) == 0 {
+		return "", fmt.Errorf("reference %s has no #key", ref)
+	}
+
+	doc, err := decrypt(path)
+	if err != nil {
+		return "", err
+	}
+
+	var current interface{} = doc
+
+	for _, part := range strings.Split(key, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("key %s not found in %s", key, path)
+		}
+
+		if current, ok = m[part]; !ok {
+			return "", fmt.Errorf("key %s not found in %s", key, path)
+		}
+	}
+
+	switch current.(type) {
+	case map[string]interface{}, []interface{}:
+		return "", fmt.Errorf("key %s in %s is not a scalar", key, path)
+	}
+
+	return fmt.Sprint(current), nil
+}
+
+func (s *sopsResolver) String() string {
+	return "sops"
+}
+
+func NewResolver() secret.Resolver {
+	return &sopsResolver{}
+}
+
+// Values decrypts a values file and returns its top level keys. Every value
+// that was encrypted in the file is recorded as a secret so it is masked.
+func Values(path string) (map[string]interface{}, error) {
+	doc, err := decrypt(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := map[interface{}]interface{}{}
+
+	if err := yaml.Unmarshal(raw, &encrypted); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	addEncrypted(doc, normalize(encrypted))
+
+	return doc, nil
+}
+
+// decrypt runs sops over the file and keeps the plaintext in memory only.
+func decrypt(path string) (map[string]interface{}, error) {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if doc, ok := decrypted[path]; ok {
+		return doc, nil
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmd := exec.Command("sops", "--decrypt", "--output-type", "yaml", path)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = os.Environ()
+
+	if keyFile := viper.GetString("age-key-file"); len(keyFile) != 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("SOPS_AGE_KEY_FILE=%s", keyFile))
+	}
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	plain := map[interface{}]interface{}{}
+
+	if err := yaml.Unmarshal(stdout.Bytes(), &plain); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted %s: %v", path, err)
+	}
+
+	doc, _ := normalize(plain).(map[string]interface{})
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+
+	// the metadata is not a value
+	delete(doc, "sops")
+
+	decrypted[path] = doc
+
+	return doc, nil
+}
+
+// addEncrypted walks the decrypted document alongside the encrypted one and
+// records the plaintext of every leaf sops encrypted.
+func addEncrypted(plain, encrypted interface{}) {
+	switch p := plain.(type) {
+	case map[string]interface{}:
+		e, _ := encrypted.(map[string]interface{})
+		for k, v := range p {
+			addEncrypted(v, e[k])
+		}
+	case []interface{}:
+		e, _ := encrypted.([]interface{})
+		for i, v := range p {
+			if i < len(e) {
+				addEncrypted(v, e[i])
+			}
+		}
+	default:
+		if e, ok := encrypted.(string); ok && strings.HasPrefix(e, "ENC[") {
+			secret.Add(fmt.Sprint(p))
+		}
+	}
+}
+
+// normalize turns the maps yaml.v2 produces into maps keyed by strings.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, v := range t {
+			m[fmt.Sprint(k)] = normalize(v)
+		}
+		return m
+	case []interface{}:
+		for i, v := range t {
+			t[i] = normalize(v)
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,195 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/secret"
+)
+
+type vaultResolver struct {
+	client *http.Client
+	mtx    sync.Mutex
+	token  string
+	cache  map[string]map[string]interface{}
+}
+
+// Resolve takes a reference of the form mount/path#key and reads key from
+// the KV v2 secret at path in the engine mounted at mount.
+func (v *vaultResolver) Resolve(ref string) (string, error) {
+	path, key, found := strings.Cut(ref, "#")
+	if !found || len(key) == 0 {
+		return "", fmt.Errorf("reference %s has no #key", ref)
+	}
+
+	data, err := v.read(path)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found at %s", key, path)
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+func (v *vaultResolver) String() string {
+	return "vault"
+}
+
+func (v *vaultResolver) read(path string) (map[string]interface{}, error) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if data, ok := v.cache[path]; ok {
+		return data, nil
+	}
+
+	mount, rest, found := strings.Cut(strings.Trim(path, "/"), "/")
+	if !found || len(rest) == 0 {
+		return nil, fmt.Errorf("path %s must be <mount>/<path>", path)
+	}
+
+	token, err := v.login()
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}{}
+
+	if err := v.do(http.MethodGet, fmt.Sprintf("/v1/%s/data/%s", mount, rest), token, nil, &rsp); err != nil {
+		return nil, err
+	}
+
+	if rsp.Data.Data == nil {
+		return nil, fmt.Errorf("no data at %s", path)
+	}
+
+	v.cache[path] = rsp.Data.Data
+
+	return rsp.Data.Data, nil
+}
+
+// login returns the configured token, or exchanges the AppRole credentials
+// for one the first time it is needed.
+func (v *vaultResolver) login() (string, error) {
+	if len(v.token) != 0 {
+		return v.token, nil
+	}
+
+	if token := viper.GetString("vault-token"); len(token) != 0 {
+		secret.Add(token)
+		v.token = token
+		return v.token, nil
+	}
+
+	roleID := viper.GetString("vault-role-id")
+	secretID := viper.GetString("vault-secret-id")
+
+	if len(roleID) == 0 || len(secretID) == 0 {
+		return "", fmt.Errorf("no vault token and no approle credentials")
+	}
+
+	secret.Add(secretID)
+
+	rsp := struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}{}
+
+	if err := v.do(http.MethodPost, "/v1/auth/approle/login", "", map[string]string{
+		"role_id":   roleID,
+		"secret_id": secretID,
+	}, &rsp); err != nil {
+		return "", fmt.Errorf("approle login failed: %v", err)
+	}
+
+	if len(rsp.Auth.ClientToken) == 0 {
+		return "", fmt.Errorf("approle login returned no token")
+	}
+
+	// the token is as sensitive as what it unlocks
+	secret.Add(rsp.Auth.ClientToken)
+
+	v.token = rsp.Auth.ClientToken
+
+	return v.token, nil
+}
+
+func (v *vaultResolver) do(method, path, token string, body, out interface{}) error {
+	addr := strings.TrimRight(viper.GetString("vault-addr"), "/")
+	if len(addr) == 0 {
+		return fmt.Errorf("no vault address")
+	}
+
+	var reqBody io.Reader
+
+	if body != nil {
+		bs, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(bs)
+	}
+
+	req, err := http.NewRequest(method, addr+path, reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(token) != 0 {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	if ns := viper.GetString("vault-namespace"); len(ns) != 0 {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	rsp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	bs, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		errs := struct {
+			Errors []string `json:"errors"`
+		}{}
+
+		json.Unmarshal(bs, &errs)
+
+		return fmt.Errorf("vault returned %d for %s: %s", rsp.StatusCode, path, strings.Join(errs.Errors, "; "))
+	}
+
+	return json.Unmarshal(bs, out)
+}
+
+func NewResolver() secret.Resolver {
+	return &vaultResolver{
+		client: &http.Client{Timeout: 30 * time.Second},
+		cache:  map[string]map[string]interface{}{},
+	}
+}
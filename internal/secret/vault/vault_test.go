@@ -0,0 +1,218 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// stubVault serves a KV v2 engine mounted at secret and the AppRole login,
+// and records what it was asked.
+type stubVault struct {
+	mtx        sync.Mutex
+	token      string
+	namespace  string
+	logins     int
+	reads      map[string]int
+	namespaces []string
+}
+
+func (s *stubVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.namespaces = append(s.namespaces, r.Header.Get("X-Vault-Namespace"))
+
+	if len(s.namespace) != 0 && r.Header.Get("X-Vault-Namespace") != s.namespace {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["wrong namespace"]}`))
+		return
+	}
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		s.logins++
+
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		if r.Method != http.MethodPost || body["role_id"] != "role" || body["secret_id"] != "sid" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["invalid role or secret id"]}`))
+			return
+		}
+
+		w.Write([]byte(`{"auth":{"client_token":"` + s.token + `"}}`))
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != s.token {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+
+	s.reads[r.URL.Path]++
+
+	switch r.URL.Path {
+	case "/v1/secret/data/platform/aws":
+		w.Write([]byte(`{"data":{"data":{"access_key":"AKIA","port":5432},"metadata":{"version":3}}}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+	}
+}
+
+func newStub(t *testing.T, token string) *stubVault {
+	t.Helper()
+
+	stub := &stubVault{
+		token: token,
+		reads: map[string]int{},
+	}
+
+	srv := httptest.NewServer(stub)
+
+	t.Cleanup(srv.Close)
+	t.Cleanup(viper.Reset)
+
+	viper.Set("vault-addr", srv.URL+"/")
+
+	return stub
+}
+
+func TestResolveWithToken(t *testing.T) {
+	stub := newStub(t, "root")
+
+	viper.Set("vault-token", "root")
+
+	v := NewResolver()
+
+	got, err := v.Resolve("secret/platform/aws#access_key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != "AKIA" {
+		t.Errorf("got %q, want AKIA", got)
+	}
+
+	// values that are not strings come back as text
+	got, err = v.Resolve("secret/platform/aws#port")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != "5432" {
+		t.Errorf("got %q, want 5432", got)
+	}
+
+	if stub.logins != 0 {
+		t.Errorf("logged in %d times with a token", stub.logins)
+	}
+}
+
+func TestResolveWithAppRole(t *testing.T) {
+	stub := newStub(t, "s.approle")
+
+	viper.Set("vault-role-id", "role")
+	viper.Set("vault-secret-id", "sid")
+
+	v := NewResolver()
+
+	got, err := v.Resolve("secret/platform/aws#access_key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != "AKIA" {
+		t.Errorf("got %q, want AKIA", got)
+	}
+
+	if _, err := v.Resolve("secret/other#key"); err == nil {
+		t.Errorf("expected an error for a missing path")
+	}
+
+	if stub.logins != 1 {
+		t.Errorf("logged in %d times, want once", stub.logins)
+	}
+}
+
+func TestResolveAppRoleRejected(t *testing.T) {
+	newStub(t, "s.approle")
+
+	viper.Set("vault-role-id", "role")
+	viper.Set("vault-secret-id", "wrong")
+
+	_, err := NewResolver().Resolve("secret/platform/aws#access_key")
+	if err == nil || !strings.Contains(err.Error(), "invalid role or secret id") {
+		t.Errorf("got %v, want the approle login error", err)
+	}
+}
+
+func TestResolveWithNamespace(t *testing.T) {
+	stub := newStub(t, "root")
+	stub.namespace = "team-a"
+
+	viper.Set("vault-token", "root")
+
+	if _, err := NewResolver().Resolve("secret/platform/aws#access_key"); err == nil {
+		t.Fatalf("expected an error without the namespace")
+	}
+
+	viper.Set("vault-namespace", "team-a")
+
+	if _, err := NewResolver().Resolve("secret/platform/aws#access_key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if last := stub.namespaces[len(stub.namespaces)-1]; last != "team-a" {
+		t.Errorf("sent namespace %q, want team-a", last)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	newStub(t, "root")
+
+	viper.Set("vault-token", "root")
+
+	v := NewResolver()
+
+	for _, test := range []struct {
+		ref  string
+		want string
+	}{
+		{"secret/platform/aws", "has no #key"},
+		{"secret/platform/aws#", "has no #key"},
+		{"secret/platform/aws#missing", "key missing not found"},
+		{"secret#key", "must be <mount>/<path>"},
+		{"secret/platform/db#password", "vault returned 404"},
+	} {
+		_, err := v.Resolve(test.ref)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want an error containing %q", test.ref, err, test.want)
+		}
+	}
+}
+
+func TestResolveCaches(t *testing.T) {
+	stub := newStub(t, "root")
+
+	viper.Set("vault-token", "root")
+
+	v := NewResolver()
+
+	for _, ref := range []string{"secret/platform/aws#access_key", "secret/platform/aws#port", "secret/platform/aws#access_key"} {
+		if _, err := v.Resolve(ref); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if n := stub.reads["/v1/secret/data/platform/aws"]; n != 1 {
+		t.Errorf("read the secret %d times, want once", n)
+	}
+}
@@ -0,0 +1,152 @@
+package step
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	clusterVersionRegexp = regexp.MustCompile(`^(latest|\d+\.\d+(\.\d+)?(-[a-z0-9.]+)?)$`)
+	clusterTagRegexp     = regexp.MustCompile(`^[a-zA-Z0-9:_-]{1,255}$`)
+)
+
+type Cluster struct {
+	Version   string     `yaml:"version,omitempty"`
+	HA        bool       `yaml:"ha,omitempty"`
+	Tags      []string   `yaml:"tags,omitempty"`
+	NodePools []NodePool `yaml:"node_pools,omitempty"`
+}
+
+type NodePool struct {
+	Name      string   `yaml:"name"`
+	Size      string   `yaml:"size"`
+	Count     int      `yaml:"count,omitempty"`
+	AutoScale bool     `yaml:"auto_scale,omitempty"`
+	MinNodes  int      `yaml:"min_nodes,omitempty"`
+	MaxNodes  int      `yaml:"max_nodes,omitempty"`
+	Tags      []string `yaml:"tags,omitempty"`
+}
+
+func (c *Cluster) Validate() error {
+	errs := []error{}
+
+	if len(c.Version) != 0 && !clusterVersionRegexp.MatchString(c.Version) {
+		errs = append(errs, fmt.Errorf("version %q is not a kubernetes version", c.Version))
+	}
+
+	errs = append(errs, validateTags(c.Tags)...)
+
+	names := map[string]bool{}
+
+	for i, np := range c.NodePools {
+		if len(np.Name) == 0 {
+			errs = append(errs, fmt.Errorf("node pool %d has no name", i))
+		} else if names[np.Name] {
+			errs = append(errs, fmt.Errorf("node pool %s is declared twice", np.Name))
+		}
+
+		names[np.Name] = true
+
+		if err := np.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("node pool %s: %w", np.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Vars returns the cluster as typed terraform vars.
+func (c *Cluster) Vars() map[string]interface{} {
+	pools := []interface{}{}
+
+	for _, np := range c.NodePools {
+		pool := map[string]interface{}{
+			"name":       np.Name,
+			"size":       np.Size,
+			"auto_scale": np.AutoScale,
+			"tags":       nonNil(np.Tags),
+		}
+
+		if np.AutoScale {
+			pool["min_nodes"] = np.MinNodes
+			pool["max_nodes"] = np.MaxNodes
+		}
+
+		if np.Count > 0 {
+			pool["node_count"] = np.Count
+		}
+
+		pools = append(pools, pool)
+	}
+
+	vars := map[string]interface{}{
+		"ha":   c.HA,
+		"tags": nonNil(c.Tags),
+	}
+
+	// without pools the module's default pool applies
+	if len(pools) != 0 {
+		vars["node_pools"] = pools
+	}
+
+	if len(c.Version) != 0 {
+		vars["kubernetes_version"] = c.Version
+	}
+
+	return vars
+}
+
+func (np *NodePool) Validate() error {
+	errs := []error{}
+
+	if len(np.Size) == 0 {
+		errs = append(errs, errors.New("size is required"))
+	}
+
+	errs = append(errs, validateTags(np.Tags)...)
+
+	if np.AutoScale {
+		if np.MinNodes < 1 {
+			errs = append(errs, fmt.Errorf("min_nodes %d must be at least 1", np.MinNodes))
+		}
+
+		if np.MaxNodes < np.MinNodes {
+			errs = append(errs, fmt.Errorf("max_nodes %d must not be less than min_nodes %d", np.MaxNodes, np.MinNodes))
+		}
+
+		if np.Count != 0 && (np.Count < np.MinNodes || np.Count > np.MaxNodes) {
+			errs = append(errs, fmt.Errorf("count %d must be between min_nodes and max_nodes", np.Count))
+		}
+	} else {
+		if np.Count < 1 {
+			errs = append(errs, fmt.Errorf("count %d must be at least 1 without auto_scale", np.Count))
+		}
+
+		if np.MinNodes != 0 || np.MaxNodes != 0 {
+			errs = append(errs, errors.New("min_nodes and max_nodes need auto_scale"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateTags(tags []string) []error {
+	errs := []error{}
+
+	for _, tag := range tags {
+		if !clusterTagRegexp.MatchString(tag) {
+			errs = append(errs, fmt.Errorf("tag %q may only contain letters, numbers, colons, dashes and underscores", tag))
+		}
+	}
+
+	return errs
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+
+	return list
+}
@@ -0,0 +1,54 @@
+package step
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/w-h-a/cli/internal/kubeconfig"
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// ExportKubeconfig merges the kubeconfig of every cloud region into the
+// user's kubeconfig under the context <provider>-<name>-<env>-<region>.
+// Kind maintains the contexts of its clusters itself.
+func (p *Platform) ExportKubeconfig(use bool) error {
+	stateName := p.Name + "check my state"
+
+	for _, r := range p.Regions {
+		context := p.kubeContext(r)
+
+		if r.Provider == "kind" {
+			fmt.Fprintf(os.Stdout, "kind keeps context %s in %s\n", context, kubeconfig.Path(context))
+			continue
+		}
+
+		if err := exportKubeconfig(p.kubeconfigTask(r, stateName), context, use); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func exportKubeconfig(config task.Task, context string, use bool) error {
+	defer config.Finalize()
+
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if err := config.Apply(); err != nil {
+		return err
+	}
+
+	dst := kubeconfig.Path(context)
+
+	if err := kubeconfig.Merge(filepath.Join(config.Options().Path, "kubeconfig"), dst, context, use); err != nil {
+		return fmt.Errorf("failed to merge kubeconfig into %s: %v", dst, err)
+	}
+
+	fmt.Fprintf(os.Stdout, "merged context %s into %s\n", context, dst)
+
+	return nil
+}
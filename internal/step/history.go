@@ -0,0 +1,99 @@
+package step
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/history"
+	"github.com/w-h-a/cli/internal/task"
+	"github.com/w-h-a/cli/internal/task/record"
+)
+
+// ServiceHistory returns the recorded deployments of the service in every
+// region, oldest first.
+func (p *Platform) ServiceHistory() ([]history.Entry, error) {
+	entries := []history.Entry{}
+
+	for _, r := range p.Regions {
+		key := history.Key(p.internalName(r, fmt.Sprintf("%s.%s", viper.GetString("service-name"), viper.GetString("service-namespace"))))
+
+		es, err := history.List(context.Background(), key)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, es...)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	return entries, nil
+}
+
+// RollbackTarget finds the deployment of the service to roll back to: the
+// latest one of version to, or without it the latest one of a version other
+// than the current.
+func (p *Platform) RollbackTarget(to string) (history.Entry, error) {
+	name := viper.GetString("service-name")
+
+	entries, err := p.ServiceHistory()
+	if err != nil {
+		return history.Entry{}, err
+	}
+
+	if len(entries) == 0 {
+		return history.Entry{}, fmt.Errorf("no recorded deployments of %s", name)
+	}
+
+	current := entries[len(entries)-1].Version
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+
+		if (len(to) != 0 && e.Version == to) || (len(to) == 0 && e.Version != current) {
+			return e, nil
+		}
+	}
+
+	if len(to) != 0 {
+		return history.Entry{}, fmt.Errorf("version %s of %s was never deployed", to, name)
+	}
+
+	return history.Entry{}, fmt.Errorf("no earlier version of %s to roll back to", name)
+}
+
+// recordStep records the deployment of a service in the history once it was
+// applied and, if gated, got ready.
+func (p *Platform) recordStep(r Region, w workload, after task.Task, gate []Step) []Step {
+	o := after.Options()
+
+	dependencies := []string{o.Name}
+
+	for _, step := range gate {
+		for _, t := range step {
+			dependencies = append(dependencies, t.Options().Name)
+		}
+	}
+
+	rec := record.NewTask(
+		task.TaskWithName(fmt.Sprintf("%s.record", o.Name)),
+		task.TaskWithTimeout(historyTimeout),
+		task.TaskWithDependencies(dependencies...),
+		record.RecordWithKey(history.Key(o.Name)),
+		record.RecordWithModule(after),
+		record.RecordWithEntry(history.Entry{
+			Platform: p.Name,
+			Env:      p.Env,
+			Region:   r.Region,
+			Service:  w.Name,
+			Version:  w.Version,
+			Image:    w.Image,
+		}),
+	)
+
+	return []Step{{rec}}
+}
@@ -0,0 +1,162 @@
+package step
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+
+	"github.com/w-h-a/cli/internal/redact"
+	"github.com/w-h-a/cli/internal/secret"
+	"github.com/w-h-a/cli/internal/task"
+)
+
+const (
+	hookAbort = "abort"
+	hookWarn  = "warn"
+)
+
+// Hook is a shell command or an HTTP call run around a phase of a task.
+type Hook struct {
+	Command   string            `yaml:"command,omitempty"`
+	URL       string            `yaml:"url,omitempty"`
+	Method    string            `yaml:"method,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	OnFailure string            `yaml:"on_failure,omitempty"`
+}
+
+type Hooks struct {
+	PrePlan     []Hook `yaml:"pre_plan,omitempty"`
+	PostPlan    []Hook `yaml:"post_plan,omitempty"`
+	PreApply    []Hook `yaml:"pre_apply,omitempty"`
+	PostApply   []Hook `yaml:"post_apply,omitempty"`
+	PreDestroy  []Hook `yaml:"pre_destroy,omitempty"`
+	PostDestroy []Hook `yaml:"post_destroy,omitempty"`
+}
+
+// hookEvent is what a hook learns about the phase it runs around.
+type hookEvent struct {
+	Task    string                 `json:"task"`
+	Phase   string                 `json:"phase"`
+	Hook    string                 `json:"hook"`
+	Result  string                 `json:"result,omitempty"`
+	Outputs map[string]interface{} `json:"outputs"`
+}
+
+func (h Hooks) of(hook string) []Hook {
+	switch hook {
+	case "pre_plan":
+		return h.PrePlan
+	case "post_plan":
+		return h.PostPlan
+	case "pre_apply":
+		return h.PreApply
+	case "post_apply":
+		return h.PostApply
+	case "pre_destroy":
+		return h.PreDestroy
+	case "post_destroy":
+		return h.PostDestroy
+	default:
+		return nil
+	}
+}
+
+func (h Hook) validate() error {
+	if (len(h.Command) == 0) == (len(h.URL) == 0) {
+		return fmt.Errorf("a hook needs either a command or a url")
+	}
+
+	switch h.OnFailure {
+	case "", hookAbort, hookWarn:
+	default:
+		return fmt.Errorf("on_failure must be %s or %s, not %s", hookAbort, hookWarn, h.OnFailure)
+	}
+
+	return nil
+}
+
+func (h Hook) run(ctx context.Context, t task.Task, event hookEvent) error {
+	if len(h.Command) != 0 {
+		return h.runCommand(ctx, t, event)
+	}
+
+	return h.call(ctx, event)
+}
+
+// runCommand runs the command with the task's env and the event as
+// CLI_TASK, CLI_PHASE, CLI_HOOK, CLI_RESULT and TF_OUTPUT_<NAME>.
+func (h Hook) runCommand(ctx context.Context, t task.Task, event hookEvent) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Env = os.Environ()
+
+	for k, v := range t.Options().EnvVars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	for k, v := range task.OutputEnv(event.Outputs) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	cmd.Env = append(
+		cmd.Env,
+		fmt.Sprintf("CLI_TASK=%s", event.Task),
+		fmt.Sprintf("CLI_PHASE=%s", event.Phase),
+		fmt.Sprintf("CLI_HOOK=%s", event.Hook),
+		fmt.Sprintf("CLI_RESULT=%s", event.Result),
+	)
+
+	return task.ExecuteCommand(cmd, event.Task, secret.Values())
+}
+
+// call sends the event as json, with every secret masked.
+func (h Hook) call(ctx context.Context, event hookEvent) error {
+	bs, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	method := h.Method
+	if len(method) == 0 {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, h.URL, bytes.NewBufferString(redact.String(string(bs), secret.Values())))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// headers often carry tokens so they may be secret references
+	for k, v := range h.Headers {
+		value, err := secret.Resolve(v)
+		if err != nil {
+			return fmt.Errorf("failed to resolve header %s: %v", k, err)
+		}
+
+		req.Header.Set(k, value)
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %v", h.URL, err)
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %d", method, h.URL, rsp.StatusCode)
+	}
+
+	return nil
+}
+
+// hooksOf returns the hooks of the component the task belongs to.
+func hooksOf(t task.Task) Hooks {
+	hooks, _ := t.Options().Context.Value("hooks_key").(Hooks)
+	return hooks
+}
@@ -0,0 +1,120 @@
+package step
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type journalEntry struct {
+	Task      string    `json:"task"`
+	Phase     string    `json:"phase"`
+	Result    string    `json:"result"`
+	Inputs    string    `json:"inputs"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// journal is an append-only record of the phases run against each task,
+// one JSON entry per line, so an interrupted run can be resumed.
+type journal struct {
+	path    string
+	entries []journalEntry
+}
+
+func (j *journal) record(e journalEntry) error {
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(bs, '\n')); err != nil {
+		return err
+	}
+
+	j.entries = append(j.entries, e)
+
+	return nil
+}
+
+// completed reports whether the last apply recorded for the task succeeded
+// with the same inputs and was neither destroyed nor rolled back since.
+func (j *journal) completed(task, inputs string) bool {
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		e := j.entries[i]
+
+		if e.Task != task {
+			continue
+		}
+
+		if e.Result != statusSucceeded || e.Phase == "destroy" || e.Phase == "rollback" {
+			return false
+		}
+
+		if e.Phase == "apply" {
+			return e.Inputs == inputs
+		}
+	}
+
+	return false
+}
+
+// openJournal starts a new journal at path, or carries on from the entries
+// already there when resuming.
+func openJournal(path string, resume bool) (*journal, error) {
+	j := &journal{
+		path:    path,
+		entries: []journalEntry{},
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		return nil, err
+	}
+
+	if !resume {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			return nil, err
+		}
+
+		return j, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		e := journalEntry{}
+
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+
+		j.entries = append(j.entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
@@ -0,0 +1,43 @@
+package step
+
+type ExecuteOption func(o *ExecuteOptions)
+
+type ExecuteOptions struct {
+	KeepGoing bool
+	Journal   string
+	Resume    bool
+}
+
+// ExecuteWithKeepGoing lets independent tasks carry on after another task
+// fails. Tasks that depend on a failed task are skipped.
+func ExecuteWithKeepGoing(k bool) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.KeepGoing = k
+	}
+}
+
+// ExecuteWithJournal records every phase run by apply and destroy to the
+// file at path.
+func ExecuteWithJournal(path string) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.Journal = path
+	}
+}
+
+// ExecuteWithResume makes apply skip tasks the journal says were applied
+// successfully in the last run with identical inputs.
+func ExecuteWithResume(r bool) ExecuteOption {
+	return func(o *ExecuteOptions) {
+		o.Resume = r
+	}
+}
+
+func NewExecuteOptions(opts ...ExecuteOption) ExecuteOptions {
+	options := ExecuteOptions{}
+
+	for _, fn := range opts {
+		fn(&options)
+	}
+
+	return options
+}
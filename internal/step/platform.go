@@ -1,33 +1,138 @@
 package step
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/history"
+	"github.com/w-h-a/cli/internal/kubeconfig"
 	"github.com/w-h-a/cli/internal/task"
+	"github.com/w-h-a/cli/internal/task/credentials"
+	"github.com/w-h-a/cli/internal/task/exec"
+	"github.com/w-h-a/cli/internal/task/helm"
+	"github.com/w-h-a/cli/internal/task/kind"
+	"github.com/w-h-a/cli/internal/task/kustomize"
+	"github.com/w-h-a/cli/internal/task/manifests"
+	"github.com/w-h-a/cli/internal/task/ready"
 	"github.com/w-h-a/cli/internal/task/state"
 	"github.com/w-h-a/cli/internal/task/terraform"
 )
 
+const (
+	minNodePort = 30000
+	maxNodePort = 32767
+
+	defaultReadyTimeout = 5 * time.Minute
+)
+
+var kindVersionRegexp = regexp.MustCompile(`^v?\d+\.\d+\.\d+$`)
+
 type Platform struct {
-	Name    string   `yaml:"name"`
-	Env     string   `yaml:"env"`
-	Domain  string   `yaml:"domain,omitempty"`
-	Regions []Region `yaml:"regions"`
+	Name       string                 `yaml:"name"`
+	Env        string                 `yaml:"env"`
+	Domain     string                 `yaml:"domain,omitempty"`
+	Regions    []Region               `yaml:"regions"`
+	Components map[string]Component   `yaml:"components,omitempty"`
+	Services   []Service              `yaml:"services,omitempty"`
+	Vars       map[string]interface{} `yaml:"vars,omitempty"`
+
+	// the declared service the service steps are built for, if any
+	service *Service
 }
 
 type Region struct {
-	Provider string `yaml:"provider"`
-	Region   string `yaml:"region"`
+	Provider  string                 `yaml:"provider"`
+	Region    string                 `yaml:"region"`
+	Cluster   *Cluster               `yaml:"cluster,omitempty"`
+	NodePorts []int                  `yaml:"node_ports,omitempty"`
+	Vars      map[string]interface{} `yaml:"vars,omitempty"`
+}
+
+// Service declares a service with the same fields as the service flags,
+// plus ready and rollout blocks that win over the service component's.
+type Service struct {
+	Name               string     `yaml:"name"`
+	Namespace          string     `yaml:"namespace,omitempty"`
+	ResourceNamespace  string     `yaml:"resource_namespace,omitempty"`
+	AppNamespace       string     `yaml:"app_namespace,omitempty"`
+	Version            string     `yaml:"version,omitempty"`
+	Type               string     `yaml:"type,omitempty"`
+	Port               int        `yaml:"port,omitempty"`
+	NodePort           int        `yaml:"node_port,omitempty"`
+	Image              string     `yaml:"image,omitempty"`
+	ImagePullPolicy    string     `yaml:"image_pull_policy,omitempty"`
+	Admin              string     `yaml:"admin,omitempty"`
+	Secret             string     `yaml:"secret,omitempty"`
+	PaymentKey         string     `yaml:"payment_key,omitempty"`
+	EnableTLS          bool       `yaml:"enable_tls,omitempty"`
+	CertProvider       string     `yaml:"cert_provider,omitempty"`
+	Hosts              []string   `yaml:"hosts,omitempty"`
+	AWSAccessKey       string     `yaml:"aws_access_key,omitempty"`
+	AWSSecretAccessKey string     `yaml:"aws_secret_access_key,omitempty"`
+	Ready              *Readiness `yaml:"ready,omitempty"`
+	Rollout            *Rollout   `yaml:"rollout,omitempty"`
+}
+
+// Readiness configures the gate that waits for a component to be ready
+// after it was applied.
+type Readiness struct {
+	Disabled bool          `yaml:"disabled,omitempty"`
+	Timeout  time.Duration `yaml:"timeout,omitempty"`
+	Selector string        `yaml:"selector,omitempty"`
+	URL      string        `yaml:"url,omitempty"`
+	Path     string        `yaml:"path,omitempty"`
+}
+
+// Rollout configures how a new version of a service replaces the running one.
+type Rollout struct {
+	Strategy string        `yaml:"strategy,omitempty"`
+	Steps    []int         `yaml:"steps,omitempty"`
+	Pause    time.Duration `yaml:"pause,omitempty"`
+}
+
+// workload is what a component deploys, whatever its type.
+type workload struct {
+	Name      string
+	Namespace string
+	Image     string
+	Version   string
+}
+
+type Component struct {
+	Type      string                 `yaml:"type,omitempty"`
+	Source    string                 `yaml:"source,omitempty"`
+	Chart     string                 `yaml:"chart,omitempty"`
+	Version   string                 `yaml:"version,omitempty"`
+	Namespace string                 `yaml:"namespace,omitempty"`
+	After     string                 `yaml:"after,omitempty"`
+	Commands  map[string]string      `yaml:"commands,omitempty"`
+	Hooks     Hooks                  `yaml:"hooks,omitempty"`
+	Ready     *Readiness             `yaml:"ready,omitempty"`
+	Rollout   *Rollout               `yaml:"rollout,omitempty"`
+	Timeout   time.Duration          `yaml:"timeout,omitempty"`
+	Vars      map[string]interface{} `yaml:"vars,omitempty"`
 }
 
 func (p *Platform) InfraSteps() ([]Step, error) {
 	steps := []Step{}
 
+	overrides, err := userVars()
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. ensure remote state is available
+	stateName := p.Name + "check my state"
+
 	stateChecker := state.NewTask(
-		task.TaskWithName(p.Name + "check my state"),
+		task.TaskWithName(stateName),
+		p.componentOptions("state"),
 	)
 
 	steps = append(steps, Step{stateChecker})
@@ -36,20 +141,48 @@ func (p *Platform) InfraSteps() ([]Step, error) {
 		// 2.1 kubernetes cluster
 		k8sName := p.internalName(r, "k8s")
 
-		vars := map[string]string{}
+		if r.Provider == "kind" {
+			k8s, err := p.kindTask(r, k8sName, stateName)
+			if err != nil {
+				return nil, err
+			}
+
+			steps = append(steps, Step{k8s})
+
+			steps = append(steps, p.execSteps(r, "k8s", k8s)...)
+
+			continue
+		}
+
+		vars := map[string]interface{}{}
 
 		vars["do_token"] = viper.GetString("do-token")
 		vars["name"] = p.Name
 		vars["region"] = r.Region
 
+		// without a cluster block the module's defaults apply
+		if r.Cluster != nil {
+			if err := r.Cluster.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid cluster in region %s: %w", r.Region, err)
+			}
+
+			for k, v := range r.Cluster.Vars() {
+				vars[k] = v
+			}
+		}
+
 		k8s := terraform.NewTask(
 			task.TaskWithName(k8sName),
+			p.componentOptions("k8s"),
 			task.TaskWithSource(fmt.Sprintf("%s/kubernetes-%s.git", viper.GetString("base-source"), r.Provider)),
 			task.TaskWithPath(fmt.Sprintf("/tmp/%s", k8sName)),
-			terraform.TerraformWithVars(vars),
+			task.TaskWithDependencies(stateName),
+			terraform.TerraformWithVars(p.mergeVars(r, "k8s", vars, overrides)),
 		)
 
 		steps = append(steps, Step{k8s})
+
+		steps = append(steps, p.execSteps(r, "k8s", k8s)...)
 	}
 
 	return steps, nil
@@ -58,63 +191,69 @@ func (p *Platform) InfraSteps() ([]Step, error) {
 func (p *Platform) K8sSteps() ([]Step, error) {
 	steps := []Step{}
 
+	overrides, err := userVars()
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. ensure remote state is available
+	stateName := p.Name + "check my state"
+
 	stateChecker := state.NewTask(
-		task.TaskWithName(p.Name + "check my state"),
+		task.TaskWithName(stateName),
+		p.componentOptions("state"),
 	)
 
 	steps = append(steps, Step{stateChecker})
 
 	for _, r := range p.Regions {
-		env := map[string]string{}
+		env := p.kubeEnv(r)
 
-		env["KUBE_CONFIG_PATH"] = "~/.kube/config"
+		dependencies := []string{stateName}
 
 		// 2.1. kubeconfig
 		if r.Provider != "kind" {
-			configName := p.internalName(r, "kubeconfig")
-
-			remoteStates := map[string]string{}
-
-			remoteStates["k8s"] = p.internalName(r, "k8s")
-
-			vars := map[string]string{}
-
-			vars["do_token"] = viper.GetString("do-token")
-			vars["kubernetes"] = r.Provider
-
-			config := terraform.NewTask(
-				task.TaskWithName(configName),
-				task.TaskWithSource(fmt.Sprintf("%s/kubeconfig.git", viper.GetString("base-source"))),
-				task.TaskWithPath(fmt.Sprintf("/tmp/%s", configName)),
-				terraform.TerraformWithRemoteStates(remoteStates),
-				terraform.TerraformWithVars(vars),
-			)
+			config := p.kubeconfigTask(r, stateName)
 
 			steps = append(steps, Step{config})
 
-			env["KUBE_CONFIG_PATH"] = fmt.Sprintf("/tmp/%s/kubeconfig", configName)
+			dependencies = append(dependencies, config.Options().Name)
+
+			env["KUBE_CONFIG_PATH"] = filepath.Join(config.Options().Path, "kubeconfig")
 		}
 
 		// 2.2. namespaces
 		namespaceName := p.internalName(r, "namespaces")
 
-		vars := map[string]string{}
+		vars := map[string]interface{}{}
 
 		vars["resource_namespace"] = strings.ToLower(fmt.Sprintf("%s-resource", p.Name))
 		vars["app_namespace"] = strings.ToLower(fmt.Sprintf("%s-app", p.Name))
 		vars["content_namespace"] = strings.ToLower(fmt.Sprintf("%s-content", p.Name))
 		vars["misc_namespace"] = strings.ToLower(fmt.Sprintf("%s-misc", p.Name))
 
-		namespace := terraform.NewTask(
-			task.TaskWithName(namespaceName),
-			task.TaskWithSource(fmt.Sprintf("%s/kubernetes-namespaces.git", viper.GetString("base-source"))),
-			task.TaskWithPath(fmt.Sprintf("/tmp/%s", namespaceName)),
-			task.TaskWithEnvVars(env),
-			terraform.TerraformWithVars(vars),
+		namespace, err := p.componentTask(
+			r,
+			"namespaces",
+			workload{},
+			overrides,
+			terraform.NewTask(
+				task.TaskWithName(namespaceName),
+				p.componentOptions("namespaces"),
+				task.TaskWithSource(fmt.Sprintf("%s/kubernetes-namespaces.git", viper.GetString("base-source"))),
+				task.TaskWithPath(fmt.Sprintf("/tmp/%s", namespaceName)),
+				task.TaskWithEnvVars(env),
+				task.TaskWithDependencies(dependencies...),
+				terraform.TerraformWithVars(p.mergeVars(r, "namespaces", vars, overrides)),
+			),
 		)
+		if err != nil {
+			return nil, err
+		}
 
 		steps = append(steps, Step{namespace})
+
+		steps = append(steps, p.execSteps(r, "namespaces", namespace)...)
 	}
 
 	return steps, nil
@@ -123,61 +262,76 @@ func (p *Platform) K8sSteps() ([]Step, error) {
 func (p *Platform) CockroachSteps() ([]Step, error) {
 	steps := []Step{}
 
+	overrides, err := userVars()
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. ensure remote state is available
+	stateName := p.Name + "check my state"
+
 	stateChecker := state.NewTask(
-		task.TaskWithName(p.Name + "check my state"),
+		task.TaskWithName(stateName),
+		p.componentOptions("state"),
 	)
 
 	steps = append(steps, Step{stateChecker})
 
 	for _, r := range p.Regions {
-		env := map[string]string{}
+		env := p.kubeEnv(r)
 
-		env["KUBE_CONFIG_PATH"] = "~/.kube/config"
+		dependencies := []string{stateName}
 
 		// 2.1. kubeconfig
 		if r.Provider != "kind" {
-			configName := p.internalName(r, "kubeconfig")
-
-			remoteStates := map[string]string{}
-
-			remoteStates["k8s"] = p.internalName(r, "k8s")
-
-			vars := map[string]string{}
-
-			vars["do_token"] = viper.GetString("do-token")
-			vars["kubernetes"] = r.Provider
-
-			config := terraform.NewTask(
-				task.TaskWithName(configName),
-				task.TaskWithSource(fmt.Sprintf("%s/kubeconfig.git", viper.GetString("base-source"))),
-				task.TaskWithPath(fmt.Sprintf("/tmp/%s", configName)),
-				terraform.TerraformWithRemoteStates(remoteStates),
-				terraform.TerraformWithVars(vars),
-			)
+			config := p.kubeconfigTask(r, stateName)
 
 			steps = append(steps, Step{config})
 
-			env["KUBE_CONFIG_PATH"] = fmt.Sprintf("/tmp/%s/kubeconfig", configName)
+			dependencies = append(dependencies, config.Options().Name)
+
+			env["KUBE_CONFIG_PATH"] = filepath.Join(config.Options().Path, "kubeconfig")
 		}
 
 		// 2.2. cockroach
 		cockroachName := p.internalName(r, fmt.Sprintf("%s.%s", "cockroachdb", viper.GetString("cockroachdb-namespace")))
 
-		vars := map[string]string{}
+		vars := map[string]interface{}{}
 
 		vars["cockroachdb_namespace"] = viper.GetString("cockroachdb-namespace")
 		vars["image_pull_policy"] = viper.GetString("image-pull-policy")
 
-		service := terraform.NewTask(
-			task.TaskWithName(cockroachName),
-			task.TaskWithSource(fmt.Sprintf("%s/kubernetes-cockroach.git", viper.GetString("base-source"))),
-			task.TaskWithPath(fmt.Sprintf("/tmp/%s", cockroachName)),
-			task.TaskWithEnvVars(env),
-			terraform.TerraformWithVars(vars),
+		w := workload{Namespace: viper.GetString("cockroachdb-namespace")}
+
+		service, err := p.componentTask(
+			r,
+			"cockroachdb",
+			w,
+			overrides,
+			terraform.NewTask(
+				task.TaskWithName(cockroachName),
+				p.componentOptions("cockroachdb"),
+				task.TaskWithSource(fmt.Sprintf("%s/kubernetes-cockroach.git", viper.GetString("base-source"))),
+				task.TaskWithPath(fmt.Sprintf("/tmp/%s", cockroachName)),
+				task.TaskWithEnvVars(env),
+				task.TaskWithDependencies(dependencies...),
+				terraform.TerraformWithVars(p.mergeVars(r, "cockroachdb", vars, overrides)),
+			),
 		)
+		if err != nil {
+			return nil, err
+		}
 
 		steps = append(steps, Step{service})
+
+		gate, err := p.readyStep(r, "cockroachdb", w, service)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, gate...)
+
+		steps = append(steps, p.execSteps(r, "cockroachdb", service)...)
 	}
 
 	return steps, nil
@@ -186,61 +340,76 @@ func (p *Platform) CockroachSteps() ([]Step, error) {
 func (p *Platform) NatsSteps() ([]Step, error) {
 	steps := []Step{}
 
+	overrides, err := userVars()
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. ensure remote state is available
+	stateName := p.Name + "check my state"
+
 	stateChecker := state.NewTask(
-		task.TaskWithName(p.Name + "check my state"),
+		task.TaskWithName(stateName),
+		p.componentOptions("state"),
 	)
 
 	steps = append(steps, Step{stateChecker})
 
 	for _, r := range p.Regions {
-		env := map[string]string{}
+		env := p.kubeEnv(r)
 
-		env["KUBE_CONFIG_PATH"] = "~/.kube/config"
+		dependencies := []string{stateName}
 
 		// 2.1. kubeconfig
 		if r.Provider != "kind" {
-			configName := p.internalName(r, "kubeconfig")
-
-			remoteStates := map[string]string{}
-
-			remoteStates["k8s"] = p.internalName(r, "k8s")
-
-			vars := map[string]string{}
-
-			vars["do_token"] = viper.GetString("do-token")
-			vars["kubernetes"] = r.Provider
-
-			config := terraform.NewTask(
-				task.TaskWithName(configName),
-				task.TaskWithSource(fmt.Sprintf("%s/kubeconfig.git", viper.GetString("base-source"))),
-				task.TaskWithPath(fmt.Sprintf("/tmp/%s", configName)),
-				terraform.TerraformWithRemoteStates(remoteStates),
-				terraform.TerraformWithVars(vars),
-			)
+			config := p.kubeconfigTask(r, stateName)
 
 			steps = append(steps, Step{config})
 
-			env["KUBE_CONFIG_PATH"] = fmt.Sprintf("/tmp/%s/kubeconfig", configName)
+			dependencies = append(dependencies, config.Options().Name)
+
+			env["KUBE_CONFIG_PATH"] = filepath.Join(config.Options().Path, "kubeconfig")
 		}
 
 		// 2.2. nats
 		natsName := p.internalName(r, fmt.Sprintf("%s.%s", "nats", viper.GetString("nats-namespace")))
 
-		vars := map[string]string{}
+		vars := map[string]interface{}{}
 
 		vars["nats_namespace"] = viper.GetString("nats-namespace")
 		vars["image_pull_policy"] = viper.GetString("image-pull-policy")
 
-		service := terraform.NewTask(
-			task.TaskWithName(natsName),
-			task.TaskWithSource(fmt.Sprintf("%s/kubernetes-nats.git", viper.GetString("base-source"))),
-			task.TaskWithPath(fmt.Sprintf("/tmp/%s", natsName)),
-			task.TaskWithEnvVars(env),
-			terraform.TerraformWithVars(vars),
+		w := workload{Namespace: viper.GetString("nats-namespace")}
+
+		service, err := p.componentTask(
+			r,
+			"nats",
+			w,
+			overrides,
+			terraform.NewTask(
+				task.TaskWithName(natsName),
+				p.componentOptions("nats"),
+				task.TaskWithSource(fmt.Sprintf("%s/kubernetes-nats.git", viper.GetString("base-source"))),
+				task.TaskWithPath(fmt.Sprintf("/tmp/%s", natsName)),
+				task.TaskWithEnvVars(env),
+				task.TaskWithDependencies(dependencies...),
+				terraform.TerraformWithVars(p.mergeVars(r, "nats", vars, overrides)),
+			),
 		)
+		if err != nil {
+			return nil, err
+		}
 
 		steps = append(steps, Step{service})
+
+		gate, err := p.readyStep(r, "nats", w, service)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, gate...)
+
+		steps = append(steps, p.execSteps(r, "nats", service)...)
 	}
 
 	return steps, nil
@@ -249,67 +418,89 @@ func (p *Platform) NatsSteps() ([]Step, error) {
 func (p *Platform) RuntimeSteps() ([]Step, error) {
 	steps := []Step{}
 
+	overrides, err := userVars()
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. ensure remote state is available
+	stateName := p.Name + "check my state"
+
 	stateChecker := state.NewTask(
-		task.TaskWithName(p.Name + "check my state"),
+		task.TaskWithName(stateName),
+		p.componentOptions("state"),
 	)
 
 	steps = append(steps, Step{stateChecker})
 
 	for _, r := range p.Regions {
-		env := map[string]string{}
+		env := p.kubeEnv(r)
 
-		env["KUBE_CONFIG_PATH"] = "~/.kube/config"
+		dependencies := []string{stateName}
 
 		// 2.1. kubeconfig
 		if r.Provider != "kind" {
-			configName := p.internalName(r, "kubeconfig")
-
-			remoteStates := map[string]string{}
-
-			remoteStates["k8s"] = p.internalName(r, "k8s")
-
-			vars := map[string]string{}
-
-			vars["do_token"] = viper.GetString("do-token")
-			vars["kubernetes"] = r.Provider
-
-			config := terraform.NewTask(
-				task.TaskWithName(configName),
-				task.TaskWithSource(fmt.Sprintf("%s/kubeconfig.git", viper.GetString("base-source"))),
-				task.TaskWithPath(fmt.Sprintf("/tmp/%s", configName)),
-				terraform.TerraformWithRemoteStates(remoteStates),
-				terraform.TerraformWithVars(vars),
-			)
+			config := p.kubeconfigTask(r, stateName)
 
 			steps = append(steps, Step{config})
 
-			env["KUBE_CONFIG_PATH"] = fmt.Sprintf("/tmp/%s/kubeconfig", configName)
+			dependencies = append(dependencies, config.Options().Name)
+
+			env["KUBE_CONFIG_PATH"] = filepath.Join(config.Options().Path, "kubeconfig")
 		}
 
 		// 2.2. runtime
 		serviceName := p.internalName(r, fmt.Sprintf("%s.%s", viper.GetString("runtime-name"), viper.GetString("runtime-namespace")))
 
-		vars := map[string]string{}
+		vars := map[string]interface{}{}
 
 		vars["resource_namespace"] = viper.GetString("runtime-resource-namespace")
 		vars["app_namespace"] = viper.GetString("runtime-app-namespace")
 		vars["service_namespace"] = viper.GetString("runtime-namespace")
 		vars["service_name"] = viper.GetString("runtime-name")
 		vars["service_version"] = viper.GetString("runtime-version")
-		vars["service_port"] = viper.GetString("runtime-port")
+		vars["service_port"] = viper.GetInt("runtime-port")
 		vars["service_image"] = viper.GetString("runtime-image")
 		vars["image_pull_policy"] = viper.GetString("runtime-pull-policy")
 
-		service := terraform.NewTask(
-			task.TaskWithName(serviceName),
-			task.TaskWithSource(fmt.Sprintf("%s/kubernetes-runtime.git", viper.GetString("base-source"))),
-			task.TaskWithPath(fmt.Sprintf("/tmp/%s", serviceName)),
-			task.TaskWithEnvVars(env),
-			terraform.TerraformWithVars(vars),
+		w := workload{
+			Name:      viper.GetString("runtime-name"),
+			Namespace: viper.GetString("runtime-namespace"),
+			Image:     viper.GetString("runtime-image"),
+			Version:   viper.GetString("runtime-version"),
+		}
+
+		service, err := p.componentTask(
+			r,
+			"runtime",
+			w,
+			overrides,
+			terraform.NewTask(
+				task.TaskWithName(serviceName),
+				p.componentOptions("runtime"),
+				task.TaskWithSource(fmt.Sprintf("%s/kubernetes-runtime.git", viper.GetString("base-source"))),
+				task.TaskWithPath(fmt.Sprintf("/tmp/%s", serviceName)),
+				task.TaskWithEnvVars(env),
+				task.TaskWithDependencies(dependencies...),
+				terraform.TerraformWithVars(p.mergeVars(r, "runtime", vars, overrides)),
+			),
 		)
+		if err != nil {
+			return nil, err
+		}
 
 		steps = append(steps, Step{service})
+
+		gate, err := p.readyStep(r, "runtime", w, service)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, gate...)
+
+		steps = append(steps, p.recordStep(r, w, service, gate)...)
+
+		steps = append(steps, p.execSteps(r, "runtime", service)...)
 	}
 
 	return steps, nil
@@ -318,48 +509,45 @@ func (p *Platform) RuntimeSteps() ([]Step, error) {
 func (p *Platform) ServiceSteps() ([]Step, error) {
 	steps := []Step{}
 
+	overrides, err := userVars()
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. ensure remote state is available
+	stateName := p.Name + "check my state"
+
 	stateChecker := state.NewTask(
-		task.TaskWithName(p.Name + "check my state"),
+		task.TaskWithName(stateName),
+		p.componentOptions("state"),
 	)
 
 	steps = append(steps, Step{stateChecker})
 
 	for _, r := range p.Regions {
-		env := map[string]string{}
+		env := p.kubeEnv(r)
 
-		env["KUBE_CONFIG_PATH"] = "~/.kube/config"
+		dependencies := []string{stateName}
 
 		// 2.1. kubeconfig
 		if r.Provider != "kind" {
-			configName := p.internalName(r, "kubeconfig")
-
-			remoteStates := map[string]string{}
-
-			remoteStates["k8s"] = p.internalName(r, "k8s")
-
-			vars := map[string]string{}
-
-			vars["do_token"] = viper.GetString("do-token")
-			vars["kubernetes"] = r.Provider
-
-			config := terraform.NewTask(
-				task.TaskWithName(configName),
-				task.TaskWithSource(fmt.Sprintf("%s/kubeconfig.git", viper.GetString("base-source"))),
-				task.TaskWithPath(fmt.Sprintf("/tmp/%s", configName)),
-				terraform.TerraformWithRemoteStates(remoteStates),
-				terraform.TerraformWithVars(vars),
-			)
+			config := p.kubeconfigTask(r, stateName)
 
 			steps = append(steps, Step{config})
 
-			env["KUBE_CONFIG_PATH"] = fmt.Sprintf("/tmp/%s/kubeconfig", configName)
+			dependencies = append(dependencies, config.Options().Name)
+
+			env["KUBE_CONFIG_PATH"] = filepath.Join(config.Options().Path, "kubeconfig")
 		}
 
 		// 2.2. service
+		if err := p.checkNodePort(r, viper.GetInt("node-port")); err != nil {
+			return nil, err
+		}
+
 		serviceName := p.internalName(r, fmt.Sprintf("%s.%s", viper.GetString("service-name"), viper.GetString("service-namespace")))
 
-		vars := map[string]string{}
+		vars := map[string]interface{}{}
 
 		vars["resource_namespace"] = viper.GetString("resource-namespace")
 		vars["app_namespace"] = viper.GetString("app-namespace")
@@ -367,33 +555,529 @@ func (p *Platform) ServiceSteps() ([]Step, error) {
 		vars["service_name"] = viper.GetString("service-name")
 		vars["service_version"] = viper.GetString("service-version")
 		vars["service_type"] = viper.GetString("service-type")
-		vars["service_port"] = viper.GetString("service-port")
-		vars["node_port"] = viper.GetString("node-port")
+		vars["service_port"] = viper.GetInt("service-port")
+		vars["node_port"] = viper.GetInt("node-port")
 		vars["service_image"] = viper.GetString("service-image")
 		vars["image_pull_policy"] = viper.GetString("image-pull-policy")
 		vars["admin"] = viper.GetString("admin")
 		vars["secret"] = viper.GetString("secret")
 		vars["payment_key"] = viper.GetString("payment-key")
-		vars["enable_tls"] = viper.GetString("enable-tls")
+		vars["enable_tls"] = viper.GetBool("enable-tls")
 		vars["cert_provider"] = viper.GetString("cert-provider")
-		vars["hosts"] = viper.GetString("hosts")
+		vars["hosts"] = splitList(viper.GetString("hosts"))
 		vars["aws_access_key"] = viper.GetString("aws-access-key")
 		vars["aws_secret_access_key"] = viper.GetString("aws-secret-access-key")
 
-		service := terraform.NewTask(
-			task.TaskWithName(serviceName),
-			task.TaskWithSource(fmt.Sprintf("%s/kubernetes-service.git", viper.GetString("base-source"))),
-			task.TaskWithPath(fmt.Sprintf("/tmp/%s", serviceName)),
-			task.TaskWithEnvVars(env),
-			terraform.TerraformWithVars(vars),
+		w := workload{
+			Name:      viper.GetString("service-name"),
+			Namespace: viper.GetString("service-namespace"),
+			Image:     viper.GetString("service-image"),
+			Version:   viper.GetString("service-version"),
+		}
+
+		// the rollback applies the module again with the version it ran before
+		rb := &rollback{
+			name:    serviceName,
+			key:     history.Key(serviceName),
+			version: viper.GetString("service-version"),
+			build: func(version string) task.Task {
+				rollbackVars := p.mergeVars(r, "service", vars, overrides)
+				rollbackVars["service_version"] = version
+
+				return terraform.NewTask(
+					task.TaskWithName(serviceName),
+					p.componentOptions("service"),
+					task.TaskWithSource(fmt.Sprintf("%s/kubernetes-service.git", viper.GetString("base-source"))),
+					task.TaskWithPath(fmt.Sprintf("/tmp/%s.rollback", serviceName)),
+					task.TaskWithEnvVars(env),
+					task.TaskWithDependencies(dependencies...),
+					terraform.TerraformWithVars(rollbackVars),
+				)
+			},
+			gate: p.gateFor(r, "service", w),
+		}
+
+		// a strategy deploys the new version next to the running one
+		release := func(candidate bool, version string, weight int) task.Task {
+			name := serviceName
+
+			releaseVars := p.mergeVars(r, "service", vars, overrides)
+			releaseVars["service_version"] = version
+			releaseVars["release_name"] = w.Name
+			releaseVars["traffic_weight"] = weight
+
+			if candidate {
+				name = fmt.Sprintf("%s.%s", serviceName, releaseSuffix(version))
+				releaseVars["release_name"] = candidateRelease(w.Name, version)
+			}
+
+			return terraform.NewTask(
+				task.TaskWithName(name),
+				p.componentOptions("service"),
+				task.TaskWithSource(fmt.Sprintf("%s/kubernetes-service.git", viper.GetString("base-source"))),
+				task.TaskWithPath(fmt.Sprintf("/tmp/%s", name)),
+				task.TaskWithEnvVars(env),
+				task.TaskWithDependencies(dependencies...),
+				terraform.TerraformWithVars(releaseVars),
+				terraform.TerraformWithRequiredVars("release_name", "traffic_weight"),
+			)
+		}
+
+		if strategy := p.strategy(); len(strategy) != 0 {
+			ro, err := p.rolloutTask(r, w, strategy, rb, release)
+			if err != nil {
+				return nil, err
+			}
+
+			steps = append(steps, Step{ro})
+
+			steps = append(steps, p.recordStep(r, w, ro, nil)...)
+
+			steps = append(steps, p.execSteps(r, "service", ro)...)
+
+			continue
+		}
+
+		service, err := p.componentTask(
+			r,
+			"service",
+			w,
+			overrides,
+			terraform.NewTask(
+				task.TaskWithName(serviceName),
+				p.componentOptions("service"),
+				task.TaskWithSource(fmt.Sprintf("%s/kubernetes-service.git", viper.GetString("base-source"))),
+				task.TaskWithPath(fmt.Sprintf("/tmp/%s", serviceName)),
+				task.TaskWithEnvVars(env),
+				task.TaskWithDependencies(dependencies...),
+				terraform.TerraformWithVars(p.mergeVars(r, "service", vars, overrides)),
+				terraform.TerraformWithOutputsBeforeApply(rb.remember),
+			),
 		)
+		if err != nil {
+			return nil, err
+		}
 
 		steps = append(steps, Step{service})
+
+		gateOpts := []task.TaskOption{}
+
+		// only the terraform module knows how to go back
+		if c := p.Components["service"]; !viper.GetBool("no-rollback") && (c.Type == "" || c.Type == "terraform") {
+			gateOpts = append(gateOpts, ready.ReadyWithRollback(rb.run))
+		}
+
+		gate, err := p.readyStep(r, "service", w, service, gateOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, gate...)
+
+		steps = append(steps, p.recordStep(r, w, service, gate)...)
+
+		steps = append(steps, p.execSteps(r, "service", service)...)
 	}
 
 	return steps, nil
 }
 
+// componentTask returns the component's terraform task unless the platform
+// config makes the component another type, in which case the task takes over
+// the terraform task's name, env and dependencies.
+func (p *Platform) componentTask(r Region, component string, w workload, overrides map[string]interface{}, tf task.Task) (task.Task, error) {
+	c := p.Components[component]
+
+	namespace := w.Namespace
+	if len(c.Namespace) != 0 {
+		namespace = c.Namespace
+	}
+
+	o := tf.Options()
+
+	switch c.Type {
+	case "", "terraform":
+		return tf, nil
+	case "helm":
+		builtIn := map[string]interface{}{}
+
+		// the chart convention for the image a release runs
+		if len(w.Image) != 0 {
+			image := map[string]interface{}{"repository": w.Image}
+			if len(w.Version) != 0 {
+				image["tag"] = w.Version
+			}
+
+			builtIn["image"] = image
+		}
+
+		return helm.NewTask(
+			task.TaskWithName(o.Name),
+			p.componentOptions(component),
+			task.TaskWithSource(c.Source),
+			task.TaskWithPath(o.Path),
+			task.TaskWithEnvVars(o.EnvVars),
+			task.TaskWithDependencies(o.Dependencies...),
+			helm.HelmWithRelease(helmRelease(component, w)),
+			helm.HelmWithChart(c.Chart),
+			helm.HelmWithVersion(c.Version),
+			helm.HelmWithNamespace(namespace),
+			helm.HelmWithValues(p.mergeVars(r, component, builtIn, scopedVars(component, overrides))),
+		), nil
+	case "manifests":
+		builtIn := map[string]interface{}{}
+
+		builtIn["platform"] = p.Name
+		builtIn["env"] = p.Env
+		builtIn["domain"] = p.Domain
+		builtIn["provider"] = r.Provider
+		builtIn["region"] = r.Region
+		builtIn["namespace"] = namespace
+
+		return manifests.NewTask(
+			task.TaskWithName(o.Name),
+			p.componentOptions(component),
+			task.TaskWithSource(c.Source),
+			task.TaskWithPath(o.Path),
+			task.TaskWithEnvVars(o.EnvVars),
+			task.TaskWithDependencies(o.Dependencies...),
+			manifests.ManifestsWithNamespace(namespace),
+			manifests.ManifestsWithValues(p.mergeVars(r, component, builtIn, overrides)),
+		), nil
+	case "kustomize":
+		return kustomize.NewTask(
+			task.TaskWithName(o.Name),
+			p.componentOptions(component),
+			task.TaskWithSource(c.Source),
+			task.TaskWithPath(o.Path),
+			task.TaskWithEnvVars(o.EnvVars),
+			task.TaskWithDependencies(o.Dependencies...),
+			kustomize.KustomizeWithOverlay(p.Env),
+			kustomize.KustomizeWithNamespace(namespace),
+			kustomize.KustomizeWithImage(w.Image, w.Version),
+		), nil
+	default:
+		return nil, fmt.Errorf("component %s has unsupported type %s", component, c.Type)
+	}
+}
+
+// readyStep returns the gate that waits for the component to be ready after
+// it was applied. service and runtime are gated unless disabled, the other
+// components only when they have a ready block.
+func (p *Platform) readyStep(r Region, component string, w workload, after task.Task, opts ...task.TaskOption) ([]Step, error) {
+	c := p.Components[component]
+
+	rd := c.Ready
+
+	// a declared service's own ready block wins
+	if component == "service" && p.service != nil && p.service.Ready != nil {
+		rd = p.service.Ready
+	}
+	if rd == nil {
+		if component != "service" && component != "runtime" {
+			return nil, nil
+		}
+
+		rd = &Readiness{}
+	}
+
+	if rd.Disabled {
+		return nil, nil
+	}
+
+	timeout := defaultReadyTimeout
+	if rd.Timeout > 0 {
+		timeout = rd.Timeout
+	}
+
+	url := rd.URL
+
+	// kind maps node ports to the same ports on the host
+	if len(url) == 0 && len(rd.Path) != 0 {
+		nodePort := viper.GetInt("node-port")
+
+		if r.Provider != "kind" || component != "service" || nodePort == 0 {
+			return nil, fmt.Errorf("a ready path needs a service with a node port in a kind region, give %s a ready url instead", component)
+		}
+
+		url = fmt.Sprintf("http://localhost:%d/%s", nodePort, strings.TrimPrefix(rd.Path, "/"))
+	}
+
+	namespace := w.Namespace
+	if len(c.Namespace) != 0 {
+		namespace = c.Namespace
+	}
+
+	o := after.Options()
+
+	selector := rd.Selector
+	if len(selector) == 0 {
+		selector = defaultSelector(component, c, w, o.Name)
+	}
+
+	gate := ready.NewTask(append([]task.TaskOption{
+		task.TaskWithName(fmt.Sprintf("%s.ready", o.Name)),
+		task.TaskWithTimeout(timeout),
+		task.TaskWithEnvVars(o.EnvVars),
+		task.TaskWithDependencies(o.Name),
+		ready.ReadyWithNamespace(namespace),
+		ready.ReadyWithSelector(selector),
+		ready.ReadyWithURL(url),
+	}, opts...)...)
+
+	return []Step{{gate}}, nil
+}
+
+// gateFor returns a func that builds the component's readiness gate for a
+// task run outside the steps, nil if the component is not gated. The ready
+// block was checked when the steps were built.
+func (p *Platform) gateFor(r Region, component string, w workload) func(t task.Task) task.Task {
+	return func(t task.Task) task.Task {
+		steps, _ := p.readyStep(r, component, w, t)
+		if len(steps) == 0 {
+			return nil
+		}
+
+		return steps[0][0]
+	}
+}
+
+// defaultSelector selects the workloads the component deployed itself, so
+// others sharing its namespace cannot hold up its gate. Manifests carry no
+// label of the cli's, so their gate waits for the whole namespace.
+func defaultSelector(component string, c Component, w workload, name string) string {
+	switch c.Type {
+	case "", "terraform":
+		if len(w.Name) != 0 {
+			return fmt.Sprintf("app=%s", w.Name)
+		}
+	case "helm":
+		return fmt.Sprintf("app.kubernetes.io/instance=%s", helmRelease(component, w))
+	case "kustomize":
+		return kustomize.Selector(name)
+	}
+
+	return ""
+}
+
+// helmRelease names a component's helm release after its workload, so that
+// each declared service gets a release of its own, or else after the
+// component. Helm wants a lowercase dns label of at most 53 characters.
+func helmRelease(component string, w workload) string {
+	name := component
+	if len(w.Name) != 0 {
+		name = w.Name
+	}
+
+	name = nonReleaseChars.ReplaceAllString(strings.ToLower(name), "-")
+
+	if len(name) > 53 {
+		name = name[:53]
+	}
+
+	return strings.Trim(name, "-")
+}
+
+// execSteps returns the exec components that run after the given component,
+// each followed by the exec components that run after it. They share the
+// component's env and get its outputs.
+func (p *Platform) execSteps(r Region, component string, after task.Task) []Step {
+	names := []string{}
+
+	for name, c := range p.Components {
+		if c.Type == "exec" && c.After == component && name != component {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	steps := []Step{}
+
+	for _, name := range names {
+		execName := p.internalName(r, name)
+
+		o := after.Options()
+
+		dependencies := append([]string{o.Name}, o.Dependencies...)
+
+		t := exec.NewTask(
+			task.TaskWithName(execName),
+			p.componentOptions(name),
+			task.TaskWithEnvVars(o.EnvVars),
+			task.TaskWithDependencies(dependencies...),
+			exec.ExecWithCommands(p.Components[name].Commands),
+			exec.ExecWithOutputsFrom(after),
+		)
+
+		steps = append(steps, Step{t})
+
+		steps = append(steps, p.execSteps(r, name, t)...)
+	}
+
+	return steps
+}
+
+// kubeconfigTask fetches the kubeconfig of the region's cloud cluster from
+// the k8s state without managing anything itself.
+func (p *Platform) kubeconfigTask(r Region, stateName string) task.Task {
+	configName := p.internalName(r, "kubeconfig")
+
+	config := credentials.NewTask(
+		task.TaskWithName(configName),
+		p.componentOptions("kubeconfig"),
+		task.TaskWithPath(fmt.Sprintf("/tmp/%s", configName)),
+		task.TaskWithDependencies(stateName),
+		credentials.CredentialsWithProvider(r.Provider),
+		credentials.CredentialsWithRemoteState(p.internalName(r, "k8s")),
+		credentials.CredentialsWithToken(viper.GetString("do-token")),
+	)
+
+	return config
+}
+
+// kubeEnv points the kubernetes providers at the user's kubeconfig and,
+// for kind, explicitly at the cluster's context.
+func (p *Platform) kubeEnv(r Region) map[string]string {
+	env := map[string]string{}
+
+	if r.Provider != "kind" {
+		env["KUBE_CONFIG_PATH"] = kubeconfig.Path("")
+		return env
+	}
+
+	context := p.kubeContext(r)
+
+	env["KUBE_CONFIG_PATH"] = kubeconfig.Path(context)
+	env["KUBE_CTX"] = context
+
+	return env
+}
+
+// kindTask creates the region's local kind cluster in place of a cloud one.
+func (p *Platform) kindTask(r Region, k8sName, stateName string) (task.Task, error) {
+	image := ""
+
+	if r.Cluster != nil && len(r.Cluster.Version) != 0 {
+		if !kindVersionRegexp.MatchString(r.Cluster.Version) {
+			return nil, fmt.Errorf("invalid cluster in region %s: kind needs a full version like 1.29.2, got %q", r.Region, r.Cluster.Version)
+		}
+
+		image = fmt.Sprintf("kindest/node:v%s", strings.TrimPrefix(r.Cluster.Version, "v"))
+	}
+
+	ports := p.kindNodePorts(r)
+
+	for _, port := range ports {
+		if port < minNodePort || port > maxNodePort {
+			return nil, fmt.Errorf("node port %d in region %s is outside %d-%d", port, r.Region, minNodePort, maxNodePort)
+		}
+	}
+
+	k8s := kind.NewTask(
+		task.TaskWithName(k8sName),
+		p.componentOptions("k8s"),
+		task.TaskWithPath(fmt.Sprintf("/tmp/%s", k8sName)),
+		task.TaskWithDependencies(stateName),
+		kind.KindWithClusterName(p.clusterName(r)),
+		kind.KindWithNodePorts(ports),
+		kind.KindWithNodeImage(image),
+	)
+
+	return k8s, nil
+}
+
+// kindNodePorts are the ports the region's kind cluster maps to the host:
+// the region's own plus the node port of every declared service.
+func (p *Platform) kindNodePorts(r Region) []int {
+	seen := map[int]bool{}
+	ports := []int{}
+
+	for _, port := range r.NodePorts {
+		if !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+
+	for _, s := range p.Services {
+		if s.NodePort != 0 && !seen[s.NodePort] {
+			seen[s.NodePort] = true
+			ports = append(ports, s.NodePort)
+		}
+	}
+
+	sort.Ints(ports)
+
+	return ports
+}
+
+// checkNodePort refuses a node port the region's kind cluster does not map
+// to the host, since the service would be unreachable from it.
+func (p *Platform) checkNodePort(r Region, port int) error {
+	if r.Provider != "kind" || port == 0 {
+		return nil
+	}
+
+	for _, mapped := range p.kindNodePorts(r) {
+		if mapped == port {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("node port %d is not mapped to the host by the kind cluster in region %s: declare it under services or the region's node_ports and recreate the cluster", port, r.Region)
+}
+
+// clusterName names the region's cluster in the user's kubeconfig, where
+// its context is <provider>-<name>, like kind's own kind-<name>.
+func (p *Platform) clusterName(r Region) string {
+	return strings.ToLower(fmt.Sprintf("%s-%s-%s", p.Name, p.Env, r.Region))
+}
+
+func (p *Platform) kubeContext(r Region) string {
+	if r.Provider == "kind" {
+		return kubeconfig.KindContext(p.clusterName(r))
+	}
+
+	return fmt.Sprintf("%s-%s", r.Provider, p.clusterName(r))
+}
+
+// componentOptions applies the settings shared by every task of the named
+// component, falling back to the global flags where the config is silent.
+func (p *Platform) componentOptions(component string) task.TaskOption {
+	c := p.Components[component]
+
+	timeout := viper.GetDuration("timeout")
+	if c.Timeout > 0 {
+		timeout = c.Timeout
+	}
+
+	retry := task.RetryPolicy{
+		MaxAttempts: viper.GetInt("retry-max-attempts"),
+		Backoff:     viper.GetDuration("retry-backoff"),
+		Patterns:    viper.GetStringSlice("retry-patterns"),
+	}
+
+	return func(o *task.TaskOptions) {
+		task.TaskWithTimeout(timeout)(o)
+		task.TaskWithRetry(retry)(o)
+		o.Context = context.WithValue(o.Context, "hooks_key", c.Hooks)
+	}
+}
+
+// splitList turns a comma separated flag into a list, dropping empty entries.
+func splitList(s string) []string {
+	list := []string{}
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) != 0 {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
 func (p *Platform) internalName(r Region, name string) string {
 	return fmt.Sprintf("%s-%s-%s-%s-%s", p.Name, p.Env, r.Region, r.Provider, name)
 }
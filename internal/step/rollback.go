@@ -0,0 +1,135 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/w-h-a/cli/internal/history"
+	"github.com/w-h-a/cli/internal/task"
+)
+
+const historyTimeout = 30 * time.Second
+
+// rollback re-applies the version a service ran before a rollout that never
+// got ready.
+type rollback struct {
+	name     string
+	key      string
+	version  string
+	previous string
+	build    func(version string) task.Task
+	gate     func(t task.Task) task.Task
+}
+
+// remember keeps the version from the outputs of the service's module as
+// they were before it was applied.
+func (rb *rollback) remember(outputs map[string]interface{}) {
+	if v, ok := outputs["service_version"].(string); ok {
+		rb.previous = v
+	}
+}
+
+// target is the version last recorded in the history, which the failed
+// rollout was not, else the one from before the apply.
+func (rb *rollback) target() string {
+	ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+	defer cancel()
+
+	entries, err := history.List(ctx, rb.key)
+	if err == nil && len(entries) != 0 {
+		return entries[len(entries)-1].Version
+	}
+
+	return rb.previous
+}
+
+// running is the version the service runs now, from the history or else
+// from the outputs of its module.
+func (rb *rollback) running() string {
+	if v := rb.target(); len(v) != 0 {
+		return v
+	}
+
+	t := rb.build(rb.version)
+
+	defer t.Finalize()
+
+	o, ok := t.(task.Outputter)
+	if !ok {
+		return ""
+	}
+
+	if err := t.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] warning: cannot tell which version is running: %v\n", rb.name, err)
+		return ""
+	}
+
+	outputs, err := o.Outputs()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] warning: cannot tell which version is running: %v\n", rb.name, err)
+		return ""
+	}
+
+	rb.remember(outputs)
+
+	return rb.previous
+}
+
+func (rb *rollback) run(err error) error {
+	rb.previous = rb.target()
+
+	if len(rb.previous) == 0 {
+		fmt.Fprintf(os.Stderr, "[%s] no previous version to roll back to\n", rb.name)
+		return err
+	}
+
+	if rb.previous == rb.version {
+		fmt.Fprintf(os.Stderr, "[%s] version %s was already running, nothing to roll back\n", rb.name, rb.version)
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "[%s] rolling back from %s to %s\n", rb.name, rb.version, rb.previous)
+
+	t := rb.build(rb.previous)
+
+	defer t.Finalize()
+
+	if rbErr := t.Validate(); rbErr != nil {
+		return errors.Join(err, fmt.Errorf("rollback to %s failed: %w", rb.previous, rbErr))
+	}
+
+	if rbErr := t.Apply(); rbErr != nil {
+		return errors.Join(err, fmt.Errorf("rollback to %s failed: %w", rb.previous, rbErr))
+	}
+
+	// the previous version is only restored once it is ready again
+	if gate := rb.gate(t); gate != nil {
+		defer gate.Finalize()
+
+		rbErr := gate.Validate()
+		if rbErr == nil {
+			rbErr = gate.Apply()
+		}
+
+		if rbErr != nil {
+			return &task.RolledBackError{
+				Err:  errors.Join(err, fmt.Errorf("%s is not ready either: %w", rb.previous, rbErr)),
+				Task: rb.name,
+				From: rb.version,
+				To:   rb.previous,
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "[%s] restored %s\n", rb.name, rb.previous)
+
+	return &task.RolledBackError{
+		Err:  err,
+		Task: rb.name,
+		From: rb.version,
+		To:   rb.previous,
+	}
+}
@@ -0,0 +1,94 @@
+package step
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/task"
+	"github.com/w-h-a/cli/internal/task/rollout"
+)
+
+var nonReleaseChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// strategy is the rollout strategy from the flag, else from the service's
+// rollout block.
+func (p *Platform) strategy() string {
+	if strategy := viper.GetString("strategy"); len(strategy) != 0 {
+		return strategy
+	}
+
+	if rl := p.rollout(); rl != nil {
+		return rl.Strategy
+	}
+
+	return ""
+}
+
+// rollout is the declared service's rollout block, else the service
+// component's.
+func (p *Platform) rollout() *Rollout {
+	if p.service != nil && p.service.Rollout != nil {
+		return p.service.Rollout
+	}
+
+	return p.Components["service"].Rollout
+}
+
+// rolloutTask returns the task that takes the service to the new version
+// with the given strategy. It stands in for the service's task, so it has
+// its name, and gates every step with the service's readiness gate.
+func (p *Platform) rolloutTask(r Region, w workload, strategy string, rb *rollback, release rollout.ReleaseFunc) (task.Task, error) {
+	c := p.Components["service"]
+
+	// only the terraform module knows about releases and weights
+	if c.Type != "" && c.Type != "terraform" {
+		return nil, fmt.Errorf("the %s strategy needs a terraform service, not %s", strategy, c.Type)
+	}
+
+	stable := release(false, w.Version, 100)
+
+	// fail on a bad ready block now rather than halfway through the rollout
+	if _, err := p.readyStep(r, "service", w, stable); err != nil {
+		return nil, err
+	}
+
+	rl := p.rollout()
+	if rl == nil {
+		rl = &Rollout{}
+	}
+
+	o := stable.Options()
+
+	return rollout.NewTask(
+		task.TaskWithName(o.Name),
+		p.componentOptions("service"),
+		task.TaskWithDependencies(o.Dependencies...),
+		rollout.RolloutWithStrategy(strategy),
+		rollout.RolloutWithSteps(rl.Steps),
+		rollout.RolloutWithPause(rl.Pause),
+		rollout.RolloutWithVersions(w.Version, rb.running),
+		rollout.RolloutWithRelease(release),
+		rollout.RolloutWithGate(func(candidate bool, version string, t task.Task) task.Task {
+			gw := w
+			if candidate {
+				gw.Name = candidateRelease(w.Name, version)
+			}
+
+			return p.gateFor(r, "service", gw)(t)
+		}),
+	), nil
+}
+
+// candidateRelease names the release of version deployed next to the
+// stable release name.
+func candidateRelease(name, version string) string {
+	return fmt.Sprintf("%s-%s", name, releaseSuffix(version))
+}
+
+// releaseSuffix turns a version into something that can go in the name of
+// a kubernetes object.
+func releaseSuffix(version string) string {
+	return strings.Trim(nonReleaseChars.ReplaceAllString(strings.ToLower(version), "-"), "-")
+}
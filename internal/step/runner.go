@@ -0,0 +1,348 @@
+package step
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+const (
+	statusPending   = "pending"
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+	statusSkipped   = "skipped"
+	statusResumed   = "resumed"
+)
+
+type phase struct {
+	name string
+	fn   func() error
+}
+
+type result struct {
+	status  string
+	retries int
+}
+
+// runner calls task lifecycle phases on behalf of the Execute functions and
+// keeps track of what happened for the final summary.
+type runner struct {
+	options  ExecuteOptions
+	out      io.Writer
+	journal  *journal
+	tasks    map[string]task.Task
+	results  map[string]*result
+	order    []string
+	errs     []error
+	prepared map[string]bool
+}
+
+// execute runs the phases of t in order. It only returns an error when the
+// whole run must stop; with keep going, failures are collected instead.
+func (r *runner) execute(t task.Task, phases ...phase) error {
+	name := t.Options().Name
+
+	res := r.result(name)
+
+	// dependencies that have not run yet (e.g. while destroying in reverse) do not block
+	for _, dep := range t.Options().Dependencies {
+		if d, ok := r.results[dep]; ok && (d.status == statusFailed || d.status == statusSkipped) {
+			fmt.Fprintf(r.out, "[%s] skipped because %s did not succeed\n", name, dep)
+			res.status = statusSkipped
+			return nil
+		}
+	}
+
+	for _, ph := range phases {
+		err := r.runHooks(t, "pre", ph.name, nil)
+
+		if err == nil {
+			err = r.run(t, ph.name, ph.fn)
+
+			if hookErr := r.runHooks(t, "post", ph.name, err); err == nil {
+				err = hookErr
+			}
+		}
+
+		r.record(t, ph.name, err)
+
+		// what was rolled back is no longer applied as far as resuming goes
+		var rolledBack *task.RolledBackError
+		if errors.As(err, &rolledBack) {
+			r.recordRollback(rolledBack.Task)
+		}
+
+		if err != nil {
+			res.status = statusFailed
+
+			r.errs = append(r.errs, fmt.Errorf("%s: %w", name, err))
+
+			if r.options.KeepGoing {
+				return nil
+			}
+
+			return r.err()
+		}
+	}
+
+	res.status = statusSucceeded
+
+	return nil
+}
+
+func (r *runner) run(t task.Task, phase string, fn func() error) error {
+	name := t.Options().Name
+	policy := t.Options().Retry
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !policy.Retryable(err) {
+			return err
+		}
+
+		wait := policy.Delay(attempt)
+
+		fmt.Fprintf(r.out, "[%s] %s failed on attempt %d of %d, retrying in %s: %v\n", name, phase, attempt, policy.MaxAttempts, wait, err)
+
+		r.result(name).retries++
+
+		time.Sleep(wait)
+	}
+}
+
+// runHooks runs the task's hooks for when (pre or post) the phase. Post hooks
+// run whether or not the phase succeeded and learn which from phaseErr. A
+// failing hook stops the task unless it only warns.
+func (r *runner) runHooks(t task.Task, when, phase string, phaseErr error) error {
+	name := fmt.Sprintf("%s_%s", when, phase)
+
+	hooks := hooksOf(t).of(name)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	event := hookEvent{
+		Task:    t.Options().Name,
+		Phase:   phase,
+		Hook:    name,
+		Outputs: map[string]interface{}{},
+	}
+
+	if when == "post" {
+		event.Result = statusSucceeded
+		if phaseErr != nil {
+			event.Result = statusFailed
+		}
+	}
+
+	if o, ok := t.(task.Outputter); ok {
+		outputs, err := o.Outputs()
+		if err != nil {
+			fmt.Fprintf(r.out, "[%s] warning: running %s hooks without outputs: %v\n", event.Task, name, err)
+		} else {
+			event.Outputs = outputs
+		}
+	}
+
+	for i, h := range hooks {
+		err := h.validate()
+
+		if err == nil {
+			err = task.RunPhase(t.Options(), name, func(ctx context.Context) error {
+				return h.run(ctx, t, event)
+			})
+		}
+
+		if err == nil {
+			continue
+		}
+
+		if h.OnFailure == hookWarn {
+			fmt.Fprintf(r.out, "[%s] warning: %s hook %d failed: %v\n", event.Task, name, i+1, err)
+			continue
+		}
+
+		return fmt.Errorf("%s hook %d failed: %w", name, i+1, err)
+	}
+
+	return nil
+}
+
+// useJournal records the phases of this run, picking up after the last run
+// when resuming.
+func (r *runner) useJournal() error {
+	if len(r.options.Journal) == 0 {
+		return nil
+	}
+
+	j, err := openJournal(r.options.Journal, r.options.Resume)
+	if err != nil {
+		return fmt.Errorf("failed to open journal %s: %v", r.options.Journal, err)
+	}
+
+	r.journal = j
+
+	return nil
+}
+
+func (r *runner) record(t task.Task, phase string, err error) {
+	if r.journal == nil {
+		return
+	}
+
+	inputs, fpErr := task.Fingerprint(t)
+	if fpErr != nil {
+		fmt.Fprintf(r.out, "[%s] failed to fingerprint inputs: %v\n", t.Options().Name, fpErr)
+	}
+
+	result := statusSucceeded
+	if err != nil {
+		result = statusFailed
+	}
+
+	if err := r.journal.record(journalEntry{
+		Task:      t.Options().Name,
+		Phase:     phase,
+		Result:    result,
+		Inputs:    inputs,
+		Timestamp: time.Now().UTC(),
+	}); err != nil {
+		fmt.Fprintf(r.out, "[%s] failed to write journal: %v\n", t.Options().Name, err)
+	}
+}
+
+func (r *runner) recordRollback(name string) {
+	if r.journal == nil {
+		return
+	}
+
+	if err := r.journal.record(journalEntry{
+		Task:      name,
+		Phase:     "rollback",
+		Result:    statusSucceeded,
+		Timestamp: time.Now().UTC(),
+	}); err != nil {
+		fmt.Fprintf(r.out, "[%s] failed to write journal: %v\n", name, err)
+	}
+}
+
+// resumed reports whether t can be skipped because the last run already
+// applied it with identical inputs.
+func (r *runner) resumed(t task.Task) bool {
+	if r.journal == nil || !r.options.Resume {
+		return false
+	}
+
+	// what this run applied may change what t checks or records, e.g. a
+	// gate must check the version that was just applied
+	for _, dep := range t.Options().Dependencies {
+		d, ok := r.tasks[dep]
+		if ok && d.Options().Role != task.RoleCredentials && r.result(dep).status == statusSucceeded {
+			return false
+		}
+	}
+
+	inputs, err := task.Fingerprint(t)
+	if err != nil || !r.journal.completed(t.Options().Name, inputs) {
+		return false
+	}
+
+	fmt.Fprintf(r.out, "[%s] already applied in the last run, skipping\n", t.Options().Name)
+
+	r.result(t.Options().Name).status = statusResumed
+
+	return true
+}
+
+// prepare validates the resumed tasks t depends on that have outputs. They
+// are not applied again, but their outputs can only be read once they are
+// set up.
+func (r *runner) prepare(t task.Task) error {
+	for _, dep := range t.Options().Dependencies {
+		d, ok := r.tasks[dep]
+		if !ok || r.prepared[dep] || r.result(dep).status != statusResumed {
+			continue
+		}
+
+		if _, ok := d.(task.Outputter); !ok {
+			continue
+		}
+
+		fmt.Fprintf(r.out, "[%s] setting up %s to read its outputs\n", t.Options().Name, dep)
+
+		if err := r.run(d, "validate", d.Validate); err != nil {
+			return fmt.Errorf("failed to set up %s to read its outputs: %w", dep, err)
+		}
+
+		r.prepared[dep] = true
+	}
+
+	return nil
+}
+
+// register records every task up front so tasks that never run show up as skipped.
+func (r *runner) register(steps []Step) {
+	for _, step := range steps {
+		for _, t := range step {
+			r.tasks[t.Options().Name] = t
+			r.result(t.Options().Name)
+		}
+	}
+}
+
+func (r *runner) result(name string) *result {
+	if res, ok := r.results[name]; ok {
+		return res
+	}
+
+	res := &result{status: statusPending}
+
+	r.results[name] = res
+	r.order = append(r.order, name)
+
+	return res
+}
+
+func (r *runner) err() error {
+	return errors.Join(r.errs...)
+}
+
+func (r *runner) summarize() {
+	w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "TASK\tSTATUS\tRETRIES")
+
+	for _, name := range r.order {
+		res := r.results[name]
+
+		status := res.status
+		if status == statusPending {
+			status = statusSkipped
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\n", name, status, res.retries)
+	}
+
+	w.Flush()
+}
+
+func newRunner(out io.Writer, steps []Step, opts ...ExecuteOption) *runner {
+	r := &runner{
+		options:  NewExecuteOptions(opts...),
+		out:      out,
+		tasks:    map[string]task.Task{},
+		results:  map[string]*result{},
+		order:    []string{},
+		errs:     []error{},
+		prepared: map[string]bool{},
+	}
+
+	r.register(steps)
+
+	return r
+}
@@ -0,0 +1,67 @@
+package step
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/secret"
+)
+
+// Service returns the service declared with the given name.
+func (p *Platform) Service(name string) (Service, bool) {
+	for _, s := range p.Services {
+		if s.Name == name {
+			return s, true
+		}
+	}
+
+	return Service{}, false
+}
+
+// UseService makes the fields of a declared service the values of the
+// service flags, so ServiceSteps deploys it with its own ready and rollout
+// blocks. A flag given on the command
+// line still wins. Every field is set, so nothing is left over from a
+// service used before.
+func (p *Platform) UseService(s Service) error {
+	values := map[string]interface{}{
+		"resource-namespace":    s.ResourceNamespace,
+		"app-namespace":         s.AppNamespace,
+		"service-namespace":     s.Namespace,
+		"service-version":       s.Version,
+		"service-type":          s.Type,
+		"service-port":          s.Port,
+		"node-port":             s.NodePort,
+		"service-image":         s.Image,
+		"image-pull-policy":     s.ImagePullPolicy,
+		"admin":                 s.Admin,
+		"secret":                s.Secret,
+		"payment-key":           s.PaymentKey,
+		"enable-tls":            s.EnableTLS,
+		"cert-provider":         s.CertProvider,
+		"hosts":                 strings.Join(s.Hosts, ","),
+		"aws-access-key":        s.AWSAccessKey,
+		"aws-secret-access-key": s.AWSSecretAccessKey,
+	}
+
+	for key, value := range values {
+		// the config may hold secret references just like the flags
+		if str, ok := value.(string); ok {
+			resolved, err := secret.Resolve(str)
+			if err != nil {
+				return fmt.Errorf("service %s: %s: %v", s.Name, key, err)
+			}
+
+			value = resolved
+		}
+
+		viper.SetDefault(key, value)
+	}
+
+	viper.Set("service-name", s.Name)
+
+	p.service = &s
+
+	return nil
+}
@@ -1,39 +1,41 @@
 package step
 
 import (
-	"strings"
+	"os"
 
 	"github.com/w-h-a/cli/internal/task"
 )
 
 type Step []task.Task
 
-func ExecuteValidate(steps []Step) error {
+func ExecuteValidate(steps []Step, opts ...ExecuteOption) error {
+	r := newRunner(os.Stdout, steps, opts...)
+	defer r.summarize()
+
 	for _, step := range steps {
 		for _, t := range step {
 			defer t.Finalize()
 
-			if err := t.Validate(); err != nil {
+			if err := r.execute(t, phase{"validate", t.Validate}); err != nil {
 				return err
 			}
 		}
 	}
 
-	return nil
+	return r.err()
 }
 
-func ExecutePlan(steps []Step) error {
-	// first find the kubeconfig
+func ExecutePlan(steps []Step, opts ...ExecuteOption) error {
+	r := newRunner(os.Stdout, steps, opts...)
+	defer r.summarize()
+
+	// first fetch the credentials
 	for _, step := range steps {
 		for _, t := range step {
-			if strings.Contains(t.Options().Source, "kubeconfig") {
+			if t.Options().Role == task.RoleCredentials {
 				defer t.Finalize()
 
-				if err := t.Validate(); err != nil {
-					return err
-				}
-
-				if err := t.Apply(); err != nil {
+				if err := r.execute(t, phase{"validate", t.Validate}, phase{"apply", t.Apply}); err != nil {
 					return err
 				}
 			}
@@ -42,57 +44,74 @@ func ExecutePlan(steps []Step) error {
 
 	for _, step := range steps {
 		for _, t := range step {
-			if !strings.Contains(t.Options().Source, "kubeconfig") {
+			if t.Options().Role != task.RoleCredentials {
 				defer t.Finalize()
 
-				if err := t.Validate(); err != nil {
-					return err
-				}
-
-				if err := t.Plan(); err != nil {
+				if err := r.execute(t, phase{"validate", t.Validate}, phase{"plan", t.Plan}); err != nil {
 					return err
 				}
 			}
 		}
 	}
 
-	return nil
+	return r.err()
 }
 
-func ExecuteApply(steps []Step) error {
+func ExecuteApply(steps []Step, opts ...ExecuteOption) error {
+	r := newRunner(os.Stdout, steps, opts...)
+	defer r.summarize()
+
+	if err := r.useJournal(); err != nil {
+		return err
+	}
+
 	for _, step := range steps {
 		for _, t := range step {
+			// a resumed task may still be set up for its outputs
 			defer t.Finalize()
 
-			if err := t.Validate(); err != nil {
-				return err
+			// credentials only live for the run so they are never resumed
+			if t.Options().Role != task.RoleCredentials && r.resumed(t) {
+				continue
+			}
+
+			validate := func() error {
+				if err := r.prepare(t); err != nil {
+					return err
+				}
+
+				return t.Validate()
 			}
 
-			if err := t.Apply(); err != nil {
+			if err := r.execute(t, phase{"validate", validate}, phase{"apply", t.Apply}); err != nil {
 				return err
 			}
 		}
 	}
 
-	return nil
+	return r.err()
 }
 
-func ExecuteDestroy(steps []Step) error {
-	// first find the kubeconfig, apply it, and then defer its destruction
+func ExecuteDestroy(steps []Step, opts ...ExecuteOption) error {
+	r := newRunner(os.Stdout, steps, opts...)
+	defer r.summarize()
+
+	// destroying never resumes, it starts the journal afresh
+	r.options.Resume = false
+
+	if err := r.useJournal(); err != nil {
+		return err
+	}
+
+	// first fetch the credentials, there is nothing of theirs to destroy
 	for _, step := range steps {
 		for _, t := range step {
-			if strings.Contains(t.Options().Source, "kubeconfig") {
+			if t.Options().Role == task.RoleCredentials {
 				defer t.Finalize()
 
-				if err := t.Validate(); err != nil {
+				if err := r.execute(t, phase{"validate", t.Validate}, phase{"apply", t.Apply}); err != nil {
 					return err
 				}
-
-				if err := t.Apply(); err != nil {
-					return err
-				}
-
-				defer t.Destroy()
 			}
 		}
 	}
@@ -100,19 +119,15 @@ func ExecuteDestroy(steps []Step) error {
 	// now destroy stuff in the reversed order in which it was created
 	for i := len(steps) - 1; i >= 0; i-- {
 		for _, t := range steps[i] {
-			if !strings.Contains(t.Options().Source, "kubeconfig") {
+			if t.Options().Role != task.RoleCredentials {
 				defer t.Finalize()
 
-				if err := t.Validate(); err != nil {
-					return err
-				}
-
-				if err := t.Destroy(); err != nil {
+				if err := r.execute(t, phase{"validate", t.Validate}, phase{"destroy", t.Destroy}); err != nil {
 					return err
 				}
 			}
 		}
 	}
 
-	return nil
+	return r.err()
 }
@@ -0,0 +1,135 @@
+package step
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/secret"
+	"gopkg.in/yaml.v2"
+)
+
+// userVars reads the --var-file and --var flags, in that order, so a --var
+// wins over any file.
+func userVars() (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+
+	for _, path := range viper.GetStringSlice("var-file") {
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read var file: %v", err)
+		}
+
+		// yaml is a superset of json so this reads both
+		fileVars := map[string]interface{}{}
+
+		if err := yaml.Unmarshal(bs, &fileVars); err != nil {
+			return nil, fmt.Errorf("failed to parse var file %s: %v", path, err)
+		}
+
+		for k, v := range fileVars {
+			if vars[k], err = resolveVar(normalizeVar(v)); err != nil {
+				return nil, fmt.Errorf("var %s in %s: %v", k, path, err)
+			}
+		}
+	}
+
+	for _, kv := range viper.GetStringSlice("var") {
+		k, v, found := strings.Cut(kv, "=")
+		if !found || len(k) == 0 {
+			return nil, fmt.Errorf("var %q is not of the form key=value", kv)
+		}
+
+		var value interface{} = v
+
+		// lists and maps are given as json, everything else as a string
+		if strings.HasPrefix(v, "[") || strings.HasPrefix(v, "{") {
+			if err := json.Unmarshal([]byte(v), &value); err != nil {
+				return nil, fmt.Errorf("var %s is not valid json: %v", k, err)
+			}
+		}
+
+		resolved, err := resolveVar(value)
+		if err != nil {
+			return nil, fmt.Errorf("var %s: %v", k, err)
+		}
+
+		vars[k] = resolved
+	}
+
+	return vars, nil
+}
+
+// scopedVars returns the user's vars given as <component>.<key>, keyed by
+// key, for components that should not get every var meant for the
+// terraform modules.
+func scopedVars(component string, user map[string]interface{}) map[string]interface{} {
+	vars := map[string]interface{}{}
+
+	for k, v := range user {
+		if key, found := strings.CutPrefix(k, component+"."); found && len(key) != 0 {
+			vars[key] = v
+		}
+	}
+
+	return vars
+}
+
+// mergeVars layers the vars of a component's task from lowest to highest
+// precedence: built in, platform, region, component, then the user's.
+func (p *Platform) mergeVars(r Region, component string, builtIn, user map[string]interface{}) map[string]interface{} {
+	vars := map[string]interface{}{}
+
+	for _, layer := range []map[string]interface{}{
+		builtIn,
+		p.Vars,
+		r.Vars,
+		p.Components[component].Vars,
+		user,
+	} {
+		for k, v := range layer {
+			vars[k] = normalizeVar(v)
+		}
+	}
+
+	return vars
+}
+
+// resolveVar resolves a string var that is a secret reference.
+func resolveVar(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+
+	return secret.Resolve(s)
+}
+
+// normalizeVar turns the maps yaml.v2 produces into maps keyed by strings
+// so they can be encoded as json.
+func normalizeVar(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, v := range t {
+			m[fmt.Sprint(k)] = normalizeVar(v)
+		}
+		return m
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, v := range t {
+			m[k] = normalizeVar(v)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, v := range t {
+			l[i] = normalizeVar(v)
+		}
+		return l
+	default:
+		return v
+	}
+}
@@ -0,0 +1,118 @@
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/w-h-a/cli/internal/redact"
+)
+
+const terminationGracePeriod = 30 * time.Second
+
+// ExecuteCommand runs cmd and streams its stdout and stderr line by line,
+// prefixed with the task's name and with every secret masked. A failure
+// comes back as an OutputError carrying what the command said on stderr.
+func ExecuteCommand(cmd *exec.Cmd, name string, secrets []string) error {
+	cancelled := &atomic.Bool{}
+
+	// give the command the chance to clean up (e.g. release a state lock)
+	// before it is killed, along with whatever it started itself, e.g. the
+	// children of sh -c
+	if cmd.Cancel != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		cmd.Cancel = func() error {
+			cancelled.Store(true)
+			return syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+		}
+		cmd.WaitDelay = terminationGracePeriod
+	}
+
+	// writers rather than pipes, so Wait gives up on the output once the
+	// grace period is over even if a child still holds it open
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	// keep what the command says on stderr so failures can be inspected
+	captured := &bytes.Buffer{}
+
+	// wait so we don't truncate output from the command
+	ioWait := make(chan struct{})
+
+	for _, ioPair := range []struct {
+		in  io.ReadCloser
+		out io.Writer
+	}{
+		{in: stdout, out: os.Stdout},
+		{in: stderr, out: io.MultiWriter(os.Stderr, captured)},
+	} {
+		go func(in io.ReadCloser, out io.Writer, done chan<- struct{}) {
+			defer func() {
+				done <- struct{}{}
+			}()
+
+			defer in.Close()
+
+			reader := bufio.NewReader(redact.NewReader(in, secrets))
+
+			for {
+				s, err := reader.ReadString('\n')
+				if err == nil || err == io.EOF {
+					if len(strings.TrimSpace(s)) != 0 {
+						fmt.Fprintf(out, "[%s] %s", name, s)
+					}
+					if err == io.EOF {
+						return
+					}
+				} else {
+					fmt.Fprintf(out, "[%s] error: %s\n", name, err.Error())
+					return
+				}
+			}
+
+		}(ioPair.in, ioPair.out, ioWait)
+	}
+
+	err := cmd.Start()
+	if err == nil {
+		err = cmd.Wait()
+
+		// nothing the command started may outlive a cancelled run
+		if cancelled.Load() {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+
+	stdoutWriter.Close()
+	stderrWriter.Close()
+
+	// wait for both routines (see above) to finish
+	// so we capture everything
+	<-ioWait
+	<-ioWait
+
+	command := strings.Join(cmd.Args[:min(2, len(cmd.Args))], " ")
+
+	if cmd.Process == nil {
+		return fmt.Errorf("failed to execute %s: %v", command, err)
+	}
+
+	if err != nil {
+		return &OutputError{
+			Err:    fmt.Errorf("%s failed: %v", command, err),
+			Output: captured.String(),
+		}
+	}
+
+	return nil
+}
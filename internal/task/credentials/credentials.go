@@ -0,0 +1,238 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/task"
+)
+
+const (
+	kubeconfigFile = "kubeconfig"
+
+	doAPI = "https://api.digitalocean.com"
+)
+
+// tfState is the part of a terraform state file we read.
+type tfState struct {
+	Outputs map[string]struct {
+		Value interface{} `json:"value"`
+	} `json:"outputs"`
+	Resources []struct {
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Instances []struct {
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// kubeconfigProvider writes the kubeconfig of a cluster to its path. It
+// reads the cluster from its terraform state and never manages anything.
+type kubeconfigProvider struct {
+	options task.TaskOptions
+}
+
+func (k *kubeconfigProvider) Options() task.TaskOptions {
+	return k.options
+}
+
+func (k *kubeconfigProvider) Validate() error {
+	return task.RunPhase(k.options, "validate", func(ctx context.Context) error {
+		switch k.provider() {
+		case "do":
+		default:
+			return fmt.Errorf("credentials for provider %s are not supported", k.provider())
+		}
+
+		if len(k.remoteState()) == 0 {
+			return fmt.Errorf("no remote state given for the credentials of %s", k.options.Name)
+		}
+
+		return os.MkdirAll(k.options.Path, 0o777)
+	})
+}
+
+func (k *kubeconfigProvider) Plan() error {
+	return nil
+}
+
+func (k *kubeconfigProvider) Apply() error {
+	return task.RunPhase(k.options, "apply", func(ctx context.Context) error {
+		state, err := k.readState(ctx)
+		if err != nil {
+			return err
+		}
+
+		config, err := k.kubeconfigDO(ctx, state)
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(k.options.Path, kubeconfigFile), config, 0o600); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "successfully wrote kubeconfig to %s\n", k.options.Path)
+
+		return nil
+	})
+}
+
+func (k *kubeconfigProvider) Destroy() error {
+	return nil
+}
+
+func (k *kubeconfigProvider) Finalize() error {
+	return os.RemoveAll(k.options.Path)
+}
+
+func (k *kubeconfigProvider) String() string {
+	return "credentials"
+}
+
+// kubeconfigDO asks the DO API for a fresh kubeconfig of the cluster in the
+// state, falling back to the one the state holds when there is no token.
+func (k *kubeconfigProvider) kubeconfigDO(ctx context.Context, state *tfState) ([]byte, error) {
+	id, raw := "", ""
+
+	if out, ok := state.Outputs["cluster_id"]; ok {
+		id = fmt.Sprint(out.Value)
+	}
+
+	for _, r := range state.Resources {
+		if r.Mode != "managed" || r.Type != "digitalocean_kubernetes_cluster" || len(r.Instances) == 0 {
+			continue
+		}
+
+		attrs := r.Instances[0].Attributes
+
+		if len(id) == 0 {
+			id, _ = attrs["id"].(string)
+		}
+
+		if configs, ok := attrs["kube_config"].([]interface{}); ok && len(configs) != 0 {
+			if config, ok := configs[0].(map[string]interface{}); ok {
+				raw, _ = config["raw_config"].(string)
+			}
+		}
+	}
+
+	if len(k.token()) == 0 {
+		if len(raw) == 0 {
+			return nil, fmt.Errorf("no token to fetch a kubeconfig and none in state %s", k.remoteState())
+		}
+
+		return []byte(raw), nil
+	}
+
+	if len(id) == 0 {
+		return nil, fmt.Errorf("no cluster found in state %s", k.remoteState())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v2/kubernetes/clusters/%s/kubeconfig", doAPI, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+k.token())
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch kubeconfig of cluster %s: %v", id, err)
+	}
+
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch kubeconfig of cluster %s: DO API returned %d", id, rsp.StatusCode)
+	}
+
+	return body, nil
+}
+
+func (k *kubeconfigProvider) readState(ctx context.Context) (*tfState, error) {
+	stateStore := viper.GetString("state-store")
+
+	switch stateStore {
+	case "aws":
+		return k.readStateAWS(ctx)
+	default:
+		return nil, fmt.Errorf("%s is not a supported remote state backend", stateStore)
+	}
+}
+
+func (k *kubeconfigProvider) readStateAWS(ctx context.Context) (*tfState, error) {
+	config := &aws.Config{
+		Region: aws.String(viper.GetString("aws-region")),
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate an aws session: %v", err)
+	}
+
+	s3Client := s3.New(sess)
+
+	read, err := s3Client.GetObjectWithContext(
+		ctx,
+		&s3.GetObjectInput{
+			Key:    aws.String(k.remoteState()),
+			Bucket: aws.String(viper.GetString("aws-s3-bucket")),
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s from the remote state backend: %v", k.remoteState(), err)
+	}
+
+	defer read.Body.Close()
+
+	state := &tfState{}
+
+	if err := json.NewDecoder(read.Body).Decode(state); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %v", k.remoteState(), err)
+	}
+
+	return state, nil
+}
+
+func (k *kubeconfigProvider) provider() string {
+	p, _ := k.options.Context.Value("credentials_provider_key").(string)
+	return p
+}
+
+func (k *kubeconfigProvider) remoteState() string {
+	rs, _ := k.options.Context.Value("credentials_remote_state_key").(string)
+	return rs
+}
+
+func (k *kubeconfigProvider) token() string {
+	t, _ := k.options.Context.Value("credentials_token_key").(string)
+	return t
+}
+
+// NewTask returns a task that produces the kubeconfig of a cluster. It
+// always has the credentials role.
+func NewTask(opts ...task.TaskOption) task.Task {
+	options := task.NewTaskOptions(append(opts, task.TaskWithRole(task.RoleCredentials))...)
+
+	k := &kubeconfigProvider{
+		options: options,
+	}
+
+	return k
+}
@@ -0,0 +1,29 @@
+package credentials
+
+import (
+	"context"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// CredentialsWithProvider sets the provider of the cluster, e.g. do.
+func CredentialsWithProvider(p string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "credentials_provider_key", p)
+	}
+}
+
+// CredentialsWithRemoteState sets the key of the cluster's terraform state.
+func CredentialsWithRemoteState(key string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "credentials_remote_state_key", key)
+	}
+}
+
+// CredentialsWithToken sets the provider API token used to fetch fresh
+// credentials. Without it the credentials stored in the state are used.
+func CredentialsWithToken(token string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "credentials_token_key", token)
+	}
+}
@@ -0,0 +1,153 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/w-h-a/cli/internal/secret"
+	"github.com/w-h-a/cli/internal/task"
+)
+
+type execExecutor struct {
+	options task.TaskOptions
+}
+
+func (e *execExecutor) Options() task.TaskOptions {
+	return e.options
+}
+
+func (e *execExecutor) Validate() error {
+	return task.RunPhase(e.options, "validate", func(ctx context.Context) error {
+		if len(e.commands()) == 0 {
+			return fmt.Errorf("no commands given for %s", e.options.Name)
+		}
+
+		for phase := range e.commands() {
+			switch phase {
+			case "validate", "plan", "apply", "destroy":
+			default:
+				return fmt.Errorf("%s is not a phase of %s", phase, e.options.Name)
+			}
+		}
+
+		if _, err := exec.LookPath("sh"); err != nil {
+			return fmt.Errorf("sh is required to run commands: %v", err)
+		}
+
+		return e.run(ctx, "validate")
+	})
+}
+
+func (e *execExecutor) Plan() error {
+	return task.RunPhase(e.options, "plan", func(ctx context.Context) error {
+		if _, ok := e.commands()["plan"]; !ok && len(e.commands()["apply"]) != 0 {
+			fmt.Fprintf(os.Stdout, "[%s] will run: %s\n", e.options.Name, e.commands()["apply"])
+			return nil
+		}
+
+		return e.run(ctx, "plan")
+	})
+}
+
+func (e *execExecutor) Apply() error {
+	return task.RunPhase(e.options, "apply", func(ctx context.Context) error {
+		return e.run(ctx, "apply")
+	})
+}
+
+func (e *execExecutor) Destroy() error {
+	return task.RunPhase(e.options, "destroy", func(ctx context.Context) error {
+		return e.run(ctx, "destroy")
+	})
+}
+
+func (e *execExecutor) Finalize() error {
+	return nil
+}
+
+func (e *execExecutor) String() string {
+	return "exec"
+}
+
+func (e *execExecutor) Inputs() map[string]interface{} {
+	return map[string]interface{}{
+		"commands": e.commands(),
+	}
+}
+
+func (e *execExecutor) run(ctx context.Context, phase string) error {
+	command, ok := e.commands()[phase]
+	if !ok || len(command) == 0 {
+		return nil
+	}
+
+	env, err := e.outputEnv(phase)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = os.Environ()
+
+	for k, v := range e.options.EnvVars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return task.ExecuteCommand(cmd, e.options.Name, secret.Values())
+}
+
+// outputEnv collects the outputs of the tasks the commands depend on. On
+// destroy those tasks are set up after this one, so missing outputs are only
+// a warning while validating and destroying.
+func (e *execExecutor) outputEnv(phase string) (map[string]string, error) {
+	env := map[string]string{}
+
+	for _, t := range e.outputsFrom() {
+		o, ok := t.(task.Outputter)
+		if !ok {
+			continue
+		}
+
+		outputs, err := o.Outputs()
+		if err != nil && (phase == "validate" || phase == "destroy") {
+			fmt.Fprintf(os.Stderr, "[%s] warning: running without the outputs of %s: %v\n", e.options.Name, t.Options().Name, err)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range task.OutputEnv(outputs) {
+			env[k] = v
+		}
+	}
+
+	return env, nil
+}
+
+func (e *execExecutor) commands() map[string]string {
+	commands, _ := e.options.Context.Value("exec_commands_key").(map[string]string)
+	return commands
+}
+
+func (e *execExecutor) outputsFrom() []task.Task {
+	tasks, _ := e.options.Context.Value("exec_outputs_from_key").([]task.Task)
+	return tasks
+}
+
+func NewTask(opts ...task.TaskOption) task.Task {
+	options := task.NewTaskOptions(opts...)
+
+	e := &execExecutor{
+		options: options,
+	}
+
+	return e
+}
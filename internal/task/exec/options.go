@@ -0,0 +1,23 @@
+package exec
+
+import (
+	"context"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// ExecWithCommands sets the shell command to run for each phase, keyed by
+// validate, plan, apply and destroy. A phase without one does nothing.
+func ExecWithCommands(commands map[string]string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "exec_commands_key", commands)
+	}
+}
+
+// ExecWithOutputsFrom sets the tasks whose outputs become env vars of the
+// commands.
+func ExecWithOutputsFrom(tasks ...task.Task) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "exec_outputs_from_key", tasks)
+	}
+}
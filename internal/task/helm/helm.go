@@ -0,0 +1,355 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/w-h-a/cli/internal/redact"
+	"github.com/w-h-a/cli/internal/secret"
+	"github.com/w-h-a/cli/internal/task"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	valuesFile = "values.yml"
+)
+
+type helmExecutor struct {
+	options task.TaskOptions
+}
+
+func (h *helmExecutor) Options() task.TaskOptions {
+	return h.options
+}
+
+func (h *helmExecutor) Validate() error {
+	return task.RunPhase(h.options, "validate", func(ctx context.Context) error {
+		if len(h.release()) == 0 {
+			return fmt.Errorf("no helm release name given")
+		}
+
+		if len(h.options.Source) == 0 {
+			return fmt.Errorf("no chart source given for release %s", h.release())
+		}
+
+		if h.fromRepo() && len(h.chart()) == 0 {
+			return fmt.Errorf("no chart given to find in repo %s", h.options.Source)
+		}
+
+		if _, err := exec.LookPath("helm"); err != nil {
+			return fmt.Errorf("helm is required to manage a helm release: %v", err)
+		}
+
+		if err := os.RemoveAll(h.options.Path); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(h.options.Path, 0o777); err != nil {
+			return err
+		}
+
+		if err := h.writeValuesFile(); err != nil {
+			return err
+		}
+
+		// rendering fetches the chart and proves the values fit it
+		if _, err := h.template(ctx); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "successfully rendered chart %s\n", h.chartRef())
+
+		return nil
+	})
+}
+
+func (h *helmExecutor) Plan() error {
+	return task.RunPhase(h.options, "plan", func(ctx context.Context) error {
+		desired, err := h.template(ctx)
+		if err != nil {
+			return err
+		}
+
+		current, err := h.manifest(ctx)
+		if err != nil {
+			return err
+		}
+
+		h.printDiff(current, desired)
+
+		return nil
+	})
+}
+
+func (h *helmExecutor) Apply() error {
+	return task.RunPhase(h.options, "apply", func(ctx context.Context) error {
+		args := []string{"upgrade", "--install", h.release()}
+		args = append(args, h.chartArgs()...)
+		args = append(args, "--create-namespace", "--values", filepath.Join(h.options.Path, valuesFile))
+
+		return h.executeHelm(ctx, args...)
+	})
+}
+
+func (h *helmExecutor) Destroy() error {
+	return task.RunPhase(h.options, "destroy", func(ctx context.Context) error {
+		exists, err := h.exists(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			fmt.Fprintf(os.Stdout, "[%s] helm release %s is already gone\n", h.options.Name, h.release())
+			return nil
+		}
+
+		return h.executeHelm(ctx, "uninstall", h.release())
+	})
+}
+
+func (h *helmExecutor) Finalize() error {
+	// the values file may hold secrets so make sure it goes even if the rest cannot
+	if err := os.Remove(filepath.Join(h.options.Path, valuesFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.RemoveAll(h.options.Path)
+}
+
+func (h *helmExecutor) String() string {
+	return "helm"
+}
+
+func (h *helmExecutor) Inputs() map[string]interface{} {
+	return map[string]interface{}{
+		"release":   h.release(),
+		"chart":     h.chart(),
+		"version":   h.version(),
+		"namespace": h.namespace(),
+		"values":    h.values(),
+	}
+}
+
+// fromRepo reports whether the source is a chart repo rather than the chart
+// itself, i.e. an oci:// reference or a local path.
+func (h *helmExecutor) fromRepo() bool {
+	return strings.HasPrefix(h.options.Source, "http://") || strings.HasPrefix(h.options.Source, "https://")
+}
+
+func (h *helmExecutor) chartRef() string {
+	if h.fromRepo() {
+		return fmt.Sprintf("%s from %s", h.chart(), h.options.Source)
+	}
+
+	return h.options.Source
+}
+
+func (h *helmExecutor) chartArgs() []string {
+	var args []string
+
+	switch {
+	case h.fromRepo():
+		args = []string{h.chart(), "--repo", h.options.Source}
+	case strings.HasPrefix(h.options.Source, "oci://"):
+		args = []string{h.options.Source}
+	default:
+		// helm runs from the task's path so local charts must be absolute
+		path, err := filepath.Abs(h.options.Source)
+		if err != nil {
+			path = h.options.Source
+		}
+
+		args = []string{path}
+	}
+
+	if len(h.version()) != 0 {
+		args = append(args, "--version", h.version())
+	}
+
+	return args
+}
+
+// clusterArgs points helm at the same cluster and namespace the terraform
+// components use.
+func (h *helmExecutor) clusterArgs() []string {
+	args := []string{"--namespace", h.namespace()}
+
+	if path := h.options.EnvVars["KUBE_CONFIG_PATH"]; len(path) != 0 {
+		args = append(args, "--kubeconfig", path)
+	}
+
+	if context := h.options.EnvVars["KUBE_CTX"]; len(context) != 0 {
+		args = append(args, "--kube-context", context)
+	}
+
+	return args
+}
+
+func (h *helmExecutor) template(ctx context.Context) (string, error) {
+	// get manifest leaves out hooks, so leave them out here too or every
+	// chart with hooks would always show a diff
+	args := []string{"template", h.release(), "--no-hooks"}
+	args = append(args, h.chartArgs()...)
+	args = append(args, "--values", filepath.Join(h.options.Path, valuesFile))
+
+	return h.outputHelm(ctx, args...)
+}
+
+// manifest returns what the release has deployed, nothing if it does not exist.
+func (h *helmExecutor) manifest(ctx context.Context) (string, error) {
+	exists, err := h.exists(ctx)
+	if err != nil || !exists {
+		return "", err
+	}
+
+	return h.outputHelm(ctx, "get", "manifest", h.release())
+}
+
+func (h *helmExecutor) exists(ctx context.Context) (bool, error) {
+	out, err := h.outputHelm(ctx, "list", "--short", "--filter", fmt.Sprintf("^%s$", regexp.QuoteMeta(h.release())))
+	if err != nil {
+		return false, err
+	}
+
+	return len(strings.TrimSpace(out)) != 0, nil
+}
+
+func (h *helmExecutor) printDiff(current, desired string) {
+	if current == desired {
+		fmt.Fprintf(os.Stdout, "[%s] helm release %s is up to date\n", h.options.Name, h.release())
+		return
+	}
+
+	if len(current) == 0 {
+		fmt.Fprintf(os.Stdout, "[%s] helm release %s will be installed\n", h.options.Name, h.release())
+	} else {
+		fmt.Fprintf(os.Stdout, "[%s] helm release %s will be upgraded\n", h.options.Name, h.release())
+	}
+
+	dmp := diffmatchpatch.New()
+
+	a, b, lines := dmp.DiffLinesToChars(current, desired)
+
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lines)
+
+	for _, d := range diffs {
+		var prefix string
+
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			prefix = "+"
+		case diffmatchpatch.DiffDelete:
+			prefix = "-"
+		default:
+			// unchanged lines are only noise
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(d.Text, "\n"), "\n") {
+			fmt.Fprintf(os.Stdout, "[%s] %s %s\n", h.options.Name, prefix, redact.String(line, secret.Values()))
+		}
+	}
+}
+
+func (h *helmExecutor) executeHelm(ctx context.Context, args ...string) error {
+	cmd := h.command(ctx, args...)
+
+	return task.ExecuteCommand(cmd, h.options.Name, secret.Values())
+}
+
+// outputHelm runs helm for what it prints rather than streaming it.
+func (h *helmExecutor) outputHelm(ctx context.Context, args ...string) (string, error) {
+	cmd := h.command(ctx, args...)
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		command := strings.Join(cmd.Args[:min(2, len(cmd.Args))], " ")
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", &task.OutputError{
+				Err:    fmt.Errorf("%s failed: %v: %s", command, err, redact.String(strings.TrimSpace(stderr.String()), secret.Values())),
+				Output: stderr.String(),
+			}
+		}
+
+		return "", fmt.Errorf("failed to execute %s: %v", command, err)
+	}
+
+	return string(out), nil
+}
+
+func (h *helmExecutor) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "helm", append(args, h.clusterArgs()...)...)
+	cmd.Dir = h.options.Path
+	cmd.Env = os.Environ()
+
+	for k, v := range h.options.EnvVars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return cmd
+}
+
+func (h *helmExecutor) writeValuesFile() error {
+	bs, err := yaml.Marshal(h.values())
+	if err != nil {
+		return fmt.Errorf("failed to encode values: %v", err)
+	}
+
+	return os.WriteFile(filepath.Join(h.options.Path, valuesFile), bs, 0o600)
+}
+
+func (h *helmExecutor) release() string {
+	release, _ := h.options.Context.Value("helm_release_key").(string)
+	return release
+}
+
+func (h *helmExecutor) chart() string {
+	chart, _ := h.options.Context.Value("helm_chart_key").(string)
+	return chart
+}
+
+func (h *helmExecutor) version() string {
+	version, _ := h.options.Context.Value("helm_version_key").(string)
+	return version
+}
+
+func (h *helmExecutor) namespace() string {
+	namespace, _ := h.options.Context.Value("helm_namespace_key").(string)
+	if len(namespace) == 0 {
+		return "default"
+	}
+
+	return namespace
+}
+
+func (h *helmExecutor) values() map[string]interface{} {
+	values := map[string]interface{}{}
+	if v, ok := h.options.Context.Value("helm_values_key").(map[string]interface{}); ok {
+		values = v
+	}
+
+	return values
+}
+
+func NewTask(opts ...task.TaskOption) task.Task {
+	options := task.NewTaskOptions(opts...)
+
+	h := &helmExecutor{
+		options: options,
+	}
+
+	return h
+}
@@ -0,0 +1,40 @@
+package helm
+
+import (
+	"context"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// HelmWithRelease sets the name of the release.
+func HelmWithRelease(name string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "helm_release_key", name)
+	}
+}
+
+// HelmWithChart sets the chart to find in the repo given as source. It is
+// not needed when the source is an oci:// reference or a local path.
+func HelmWithChart(chart string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "helm_chart_key", chart)
+	}
+}
+
+func HelmWithVersion(version string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "helm_version_key", version)
+	}
+}
+
+func HelmWithNamespace(namespace string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "helm_namespace_key", namespace)
+	}
+}
+
+func HelmWithValues(values map[string]interface{}) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "helm_values_key", values)
+	}
+}
@@ -0,0 +1,47 @@
+package task
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Inputter is implemented by tasks whose inputs go beyond their TaskOptions,
+// e.g. the variables handed to terraform.
+type Inputter interface {
+	Inputs() map[string]interface{}
+}
+
+// Fingerprint hashes everything that feeds a task so two runs with the same
+// fingerprint are expected to produce the same result.
+func Fingerprint(t Task) (string, error) {
+	o := t.Options()
+
+	inputs := map[string]interface{}{
+		"type":    t.String(),
+		"name":    o.Name,
+		"source":  o.Source,
+		"path":    o.Path,
+		"envVars": o.EnvVars,
+	}
+
+	if i, ok := t.(Inputter); ok {
+		inputs["inputs"] = i.Inputs()
+	}
+
+	// maps are encoded with sorted keys so this is stable
+	bs, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(bs)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Revisioner is implemented by tasks deployed from version control and
+// reports the revision that was deployed.
+type Revisioner interface {
+	Revision() string
+}
@@ -0,0 +1,239 @@
+package kind
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/w-h-a/cli/internal/task"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	configFile = "kind-config.yml"
+)
+
+type portMapping struct {
+	ContainerPort int `yaml:"containerPort"`
+	HostPort      int `yaml:"hostPort"`
+}
+
+type node struct {
+	Role              string        `yaml:"role"`
+	Image             string        `yaml:"image,omitempty"`
+	ExtraPortMappings []portMapping `yaml:"extraPortMappings,omitempty"`
+}
+
+type config struct {
+	Kind       string `yaml:"kind"`
+	APIVersion string `yaml:"apiVersion"`
+	Nodes      []node `yaml:"nodes"`
+}
+
+type kindExecutor struct {
+	options task.TaskOptions
+}
+
+func (k *kindExecutor) Options() task.TaskOptions {
+	return k.options
+}
+
+func (k *kindExecutor) Validate() error {
+	return task.RunPhase(k.options, "validate", func(ctx context.Context) error {
+		if len(k.clusterName()) == 0 {
+			return fmt.Errorf("no kind cluster name given")
+		}
+
+		for _, bin := range []string{"kind", "docker"} {
+			if _, err := exec.LookPath(bin); err != nil {
+				return fmt.Errorf("%s is required to manage a kind cluster: %v", bin, err)
+			}
+		}
+
+		if err := os.MkdirAll(k.options.Path, 0o777); err != nil {
+			return err
+		}
+
+		if err := k.writeConfigFile(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "successfully wrote kind config to %s\n", k.options.Path)
+
+		return nil
+	})
+}
+
+func (k *kindExecutor) Plan() error {
+	return task.RunPhase(k.options, "plan", func(ctx context.Context) error {
+		exists, err := k.exists(ctx)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			fmt.Fprintf(os.Stdout, "[%s] kind cluster %s exists, nothing to do\n", k.options.Name, k.clusterName())
+			return k.warnUnmapped(ctx)
+		}
+
+		bs, err := os.ReadFile(filepath.Join(k.options.Path, configFile))
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "[%s] kind cluster %s will be created with config:\n", k.options.Name, k.clusterName())
+
+		for _, line := range strings.Split(strings.TrimSpace(string(bs)), "\n") {
+			fmt.Fprintf(os.Stdout, "[%s]   %s\n", k.options.Name, line)
+		}
+
+		return nil
+	})
+}
+
+func (k *kindExecutor) Apply() error {
+	return task.RunPhase(k.options, "apply", func(ctx context.Context) error {
+		exists, err := k.exists(ctx)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			fmt.Fprintf(os.Stdout, "[%s] kind cluster %s already exists\n", k.options.Name, k.clusterName())
+			return k.warnUnmapped(ctx)
+		}
+
+		return k.executeKind(ctx, "create", "cluster", "--name", k.clusterName(), "--config", filepath.Join(k.options.Path, configFile))
+	})
+}
+
+func (k *kindExecutor) Destroy() error {
+	return task.RunPhase(k.options, "destroy", func(ctx context.Context) error {
+		// kind reports success when the cluster is already gone
+		return k.executeKind(ctx, "delete", "cluster", "--name", k.clusterName())
+	})
+}
+
+func (k *kindExecutor) Finalize() error {
+	return os.RemoveAll(k.options.Path)
+}
+
+func (k *kindExecutor) String() string {
+	return "kind"
+}
+
+func (k *kindExecutor) Inputs() map[string]interface{} {
+	return map[string]interface{}{
+		"clusterName": k.clusterName(),
+		"nodePorts":   k.nodePorts(),
+		"nodeImage":   k.nodeImage(),
+	}
+}
+
+func (k *kindExecutor) exists(ctx context.Context) (bool, error) {
+	out, err := exec.CommandContext(ctx, "kind", "get", "clusters").Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to list kind clusters: %v", err)
+	}
+
+	for _, name := range strings.Fields(string(out)) {
+		if name == k.clusterName() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// warnUnmapped points out node ports an existing cluster was created
+// without, since kind cannot add port mappings to a running cluster.
+func (k *kindExecutor) warnUnmapped(ctx context.Context) error {
+	out, err := exec.CommandContext(ctx, "docker", "port", k.clusterName()+"-control-plane").Output()
+	if err != nil {
+		return fmt.Errorf("failed to inspect the ports of kind cluster %s: %v", k.clusterName(), err)
+	}
+
+	mapped := map[string]bool{}
+
+	// lines look like 30950/tcp -> 0.0.0.0:30950
+	for _, line := range strings.Split(string(out), "\n") {
+		if port, _, found := strings.Cut(strings.TrimSpace(line), "/"); found {
+			mapped[port] = true
+		}
+	}
+
+	for _, port := range k.nodePorts() {
+		if !mapped[strconv.Itoa(port)] {
+			fmt.Fprintf(os.Stderr, "[%s] warning: node port %d is not mapped to the host, destroy and recreate the cluster to map it\n", k.options.Name, port)
+		}
+	}
+
+	return nil
+}
+
+func (k *kindExecutor) executeKind(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "kind", args...)
+	cmd.Dir = k.options.Path
+	cmd.Env = os.Environ()
+
+	for key, v := range k.options.EnvVars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, v))
+	}
+
+	return task.ExecuteCommand(cmd, k.options.Name, nil)
+}
+
+func (k *kindExecutor) writeConfigFile() error {
+	controlPlane := node{
+		Role:              "control-plane",
+		Image:             k.nodeImage(),
+		ExtraPortMappings: []portMapping{},
+	}
+
+	for _, port := range k.nodePorts() {
+		controlPlane.ExtraPortMappings = append(controlPlane.ExtraPortMappings, portMapping{
+			ContainerPort: port,
+			HostPort:      port,
+		})
+	}
+
+	bs, err := yaml.Marshal(config{
+		Kind:       "Cluster",
+		APIVersion: "kind.x-k8s.io/v1alpha4",
+		Nodes:      []node{controlPlane},
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(k.options.Path, configFile), bs, 0o644)
+}
+
+func (k *kindExecutor) clusterName() string {
+	name, _ := k.options.Context.Value("kind_cluster_name_key").(string)
+	return name
+}
+
+func (k *kindExecutor) nodePorts() []int {
+	ports, _ := k.options.Context.Value("kind_node_ports_key").([]int)
+	return ports
+}
+
+func (k *kindExecutor) nodeImage() string {
+	image, _ := k.options.Context.Value("kind_node_image_key").(string)
+	return image
+}
+
+func NewTask(opts ...task.TaskOption) task.Task {
+	options := task.NewTaskOptions(opts...)
+
+	k := &kindExecutor{
+		options: options,
+	}
+
+	return k
+}
@@ -0,0 +1,29 @@
+package kind
+
+import (
+	"context"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+func KindWithClusterName(name string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "kind_cluster_name_key", name)
+	}
+}
+
+// KindWithNodePorts maps each node port of the cluster to the same port on
+// the host so NodePort services are reachable from it.
+func KindWithNodePorts(ports []int) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "kind_node_ports_key", ports)
+	}
+}
+
+// KindWithNodeImage sets the kindest/node image, and so the kubernetes
+// version, of the cluster's node.
+func KindWithNodeImage(image string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "kind_node_image_key", image)
+	}
+}
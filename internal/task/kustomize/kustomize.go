@@ -0,0 +1,296 @@
+package kustomize
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/w-h-a/cli/internal/secret"
+	"github.com/w-h-a/cli/internal/task"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	kustomizationFile = "kustomization.yml"
+	buildFile         = "build.yml"
+
+	fieldManager = "cli"
+
+	// instanceLabel marks what one task applied so apply can prune the rest.
+	instanceLabel = "cli/instance"
+
+	maxLabelValue = 63
+)
+
+type image struct {
+	Name   string `yaml:"name"`
+	NewTag string `yaml:"newTag"`
+}
+
+type labels struct {
+	Pairs map[string]string `yaml:"pairs"`
+}
+
+type kustomization struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Resources  []string `yaml:"resources"`
+	Namespace  string   `yaml:"namespace,omitempty"`
+	Images     []image  `yaml:"images,omitempty"`
+	Labels     []labels `yaml:"labels"`
+}
+
+type kustomizeExecutor struct {
+	options task.TaskOptions
+}
+
+func (k *kustomizeExecutor) Options() task.TaskOptions {
+	return k.options
+}
+
+func (k *kustomizeExecutor) Validate() error {
+	return task.RunPhase(k.options, "validate", func(ctx context.Context) error {
+		if _, err := exec.LookPath("kubectl"); err != nil {
+			return fmt.Errorf("kubectl is required to apply kustomize overlays: %v", err)
+		}
+
+		overlay, err := k.overlayPath()
+		if err != nil {
+			return err
+		}
+
+		if info, err := os.Stat(overlay); err != nil || !info.IsDir() {
+			return fmt.Errorf("no overlay %s in %s", k.overlay(), k.options.Source)
+		}
+
+		if err := os.RemoveAll(k.options.Path); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(k.options.Path, 0o777); err != nil {
+			return err
+		}
+
+		if err := k.writeKustomization(overlay); err != nil {
+			return err
+		}
+
+		out, err := k.command(ctx, "kustomize", k.options.Path).Output()
+		if err != nil {
+			return fmt.Errorf("failed to build overlay %s: %v", k.overlay(), exitMessage(err))
+		}
+
+		if err := os.WriteFile(filepath.Join(k.options.Path, buildFile), out, 0o600); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "successfully built overlay %s to %s\n", k.overlay(), k.options.Path)
+
+		return nil
+	})
+}
+
+func (k *kustomizeExecutor) Plan() error {
+	return task.RunPhase(k.options, "plan", func(ctx context.Context) error {
+		cmd := k.clusterCommand(ctx, "diff", "--server-side", "--field-manager", fieldManager, "-f", filepath.Join(k.options.Path, buildFile))
+
+		err := task.ExecuteCommand(cmd, k.options.Name, secret.Values())
+
+		// kubectl diff exits with 1 when there are differences
+		if err != nil && cmd.ProcessState != nil && cmd.ProcessState.ExitCode() == 1 {
+			return nil
+		}
+
+		if err == nil {
+			fmt.Fprintf(os.Stdout, "[%s] overlay %s is up to date\n", k.options.Name, k.overlay())
+		}
+
+		return err
+	})
+}
+
+func (k *kustomizeExecutor) Apply() error {
+	return task.RunPhase(k.options, "apply", func(ctx context.Context) error {
+		return k.executeKubectl(
+			ctx,
+			"apply",
+			"--server-side",
+			"--field-manager", fieldManager,
+			"--prune",
+			"--selector", fmt.Sprintf("%s=%s", instanceLabel, k.instance()),
+			"-f", filepath.Join(k.options.Path, buildFile),
+		)
+	})
+}
+
+func (k *kustomizeExecutor) Destroy() error {
+	return task.RunPhase(k.options, "destroy", func(ctx context.Context) error {
+		return k.executeKubectl(ctx, "delete", "--ignore-not-found", "-f", filepath.Join(k.options.Path, buildFile))
+	})
+}
+
+func (k *kustomizeExecutor) Finalize() error {
+	return os.RemoveAll(k.options.Path)
+}
+
+func (k *kustomizeExecutor) String() string {
+	return "kustomize"
+}
+
+func (k *kustomizeExecutor) Inputs() map[string]interface{} {
+	return map[string]interface{}{
+		"overlay":   k.overlay(),
+		"namespace": k.namespace(),
+		"image":     k.image(),
+		"tag":       k.tag(),
+		"files":     k.digests(),
+	}
+}
+
+func (k *kustomizeExecutor) overlayPath() (string, error) {
+	if len(k.overlay()) == 0 {
+		return "", fmt.Errorf("no overlay given for %s", k.options.Source)
+	}
+
+	// the wrapper kustomization lives in the task's path
+	return filepath.Abs(filepath.Join(k.options.Source, "overlays", k.overlay()))
+}
+
+// writeKustomization wraps the overlay to set the namespace, the image tag
+// and the instance label without touching the overlay itself.
+func (k *kustomizeExecutor) writeKustomization(overlay string) error {
+	wrapper := kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  []string{overlay},
+		Namespace:  k.namespace(),
+		Labels: []labels{
+			{Pairs: map[string]string{instanceLabel: k.instance()}},
+		},
+	}
+
+	if len(k.image()) != 0 && len(k.tag()) != 0 {
+		wrapper.Images = []image{{Name: k.image(), NewTag: k.tag()}}
+	}
+
+	bs, err := yaml.Marshal(wrapper)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(k.options.Path, kustomizationFile), bs, 0o644)
+}
+
+// instance is the task's name as a label value, shortened with a hash of it
+// when too long.
+func (k *kustomizeExecutor) instance() string {
+	return instance(k.options.Name)
+}
+
+// digests hashes every file of the source so an edit is never resumed.
+func (k *kustomizeExecutor) digests() map[string]string {
+	digests := map[string]string{}
+
+	filepath.WalkDir(k.options.Source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(k.options.Source, path)
+
+		digests[rel] = fmt.Sprintf("%x", sha256.Sum256(bs))
+
+		return nil
+	})
+
+	return digests
+}
+
+func (k *kustomizeExecutor) executeKubectl(ctx context.Context, args ...string) error {
+	return task.ExecuteCommand(k.clusterCommand(ctx, args...), k.options.Name, secret.Values())
+}
+
+// clusterCommand points kubectl at the same cluster the terraform components use.
+func (k *kustomizeExecutor) clusterCommand(ctx context.Context, args ...string) *exec.Cmd {
+	if path := k.options.EnvVars["KUBE_CONFIG_PATH"]; len(path) != 0 {
+		args = append(args, "--kubeconfig", path)
+	}
+
+	if context := k.options.EnvVars["KUBE_CTX"]; len(context) != 0 {
+		args = append(args, "--context", context)
+	}
+
+	return k.command(ctx, args...)
+}
+
+func (k *kustomizeExecutor) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Dir = k.options.Path
+	cmd.Env = os.Environ()
+
+	for key, v := range k.options.EnvVars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, v))
+	}
+
+	return cmd
+}
+
+func (k *kustomizeExecutor) overlay() string {
+	overlay, _ := k.options.Context.Value("kustomize_overlay_key").(string)
+	return overlay
+}
+
+func (k *kustomizeExecutor) namespace() string {
+	namespace, _ := k.options.Context.Value("kustomize_namespace_key").(string)
+	return namespace
+}
+
+func (k *kustomizeExecutor) image() string {
+	image, _ := k.options.Context.Value("kustomize_image_key").(string)
+	return image
+}
+
+func (k *kustomizeExecutor) tag() string {
+	tag, _ := k.options.Context.Value("kustomize_tag_key").(string)
+	return tag
+}
+
+func exitMessage(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) != 0 {
+		return fmt.Errorf("%v: %s", err, exitErr.Stderr)
+	}
+
+	return err
+}
+
+func NewTask(opts ...task.TaskOption) task.Task {
+	options := task.NewTaskOptions(opts...)
+
+	k := &kustomizeExecutor{
+		options: options,
+	}
+
+	return k
+}
+
+// Selector selects everything the task with the given name applied.
+func Selector(name string) string {
+	return fmt.Sprintf("%s=%s", instanceLabel, instance(name))
+}
+
+func instance(name string) string {
+	if len(name) <= maxLabelValue {
+		return name
+	}
+
+	return fmt.Sprintf("%s-%x", name[:maxLabelValue-9], sha256.Sum256([]byte(name)))[:maxLabelValue]
+}
@@ -0,0 +1,29 @@
+package kustomize
+
+import (
+	"context"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// KustomizeWithOverlay selects the overlay under <source>/overlays to build.
+func KustomizeWithOverlay(overlay string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "kustomize_overlay_key", overlay)
+	}
+}
+
+// KustomizeWithNamespace sets the namespace of everything the overlay builds.
+func KustomizeWithNamespace(namespace string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "kustomize_namespace_key", namespace)
+	}
+}
+
+// KustomizeWithImage sets the tag of the image wherever the overlay uses it.
+func KustomizeWithImage(image, tag string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "kustomize_image_key", image)
+		o.Context = context.WithValue(o.Context, "kustomize_tag_key", tag)
+	}
+}
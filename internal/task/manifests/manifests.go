@@ -0,0 +1,296 @@
+package manifests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/w-h-a/cli/internal/secret"
+	"github.com/w-h-a/cli/internal/task"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	applyFile   = "apply.yml"
+	destroyFile = "destroy.yml"
+
+	fieldManager = "cli"
+)
+
+var separatorRegexp = regexp.MustCompile(`(?m)^---\s*$`)
+
+// object is one rendered manifest along with what it takes to order it.
+type object struct {
+	Kind     string
+	Name     string
+	Manifest string
+}
+
+type manifestsExecutor struct {
+	options task.TaskOptions
+}
+
+func (m *manifestsExecutor) Options() task.TaskOptions {
+	return m.options
+}
+
+func (m *manifestsExecutor) Validate() error {
+	return task.RunPhase(m.options, "validate", func(ctx context.Context) error {
+		if _, err := exec.LookPath("kubectl"); err != nil {
+			return fmt.Errorf("kubectl is required to apply manifests: %v", err)
+		}
+
+		files, err := m.files()
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			return fmt.Errorf("no manifests found in %s", m.options.Source)
+		}
+
+		if err := os.RemoveAll(m.options.Path); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(m.options.Path, 0o777); err != nil {
+			return err
+		}
+
+		objects, err := m.render(files)
+		if err != nil {
+			return err
+		}
+
+		sort.SliceStable(objects, func(i, j int) bool {
+			return kindRank(objects[i].Kind) < kindRank(objects[j].Kind)
+		})
+
+		if err := m.writeObjects(applyFile, objects); err != nil {
+			return err
+		}
+
+		for i, j := 0, len(objects)-1; i < j; i, j = i+1, j-1 {
+			objects[i], objects[j] = objects[j], objects[i]
+		}
+
+		if err := m.writeObjects(destroyFile, objects); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "successfully rendered %d manifests to %s\n", len(objects), m.options.Path)
+
+		return nil
+	})
+}
+
+func (m *manifestsExecutor) Plan() error {
+	return task.RunPhase(m.options, "plan", func(ctx context.Context) error {
+		cmd := m.command(ctx, "diff", "--server-side", "--field-manager", fieldManager, "-f", filepath.Join(m.options.Path, applyFile))
+
+		err := task.ExecuteCommand(cmd, m.options.Name, secret.Values())
+
+		// kubectl diff exits with 1 when there are differences
+		if err != nil && cmd.ProcessState != nil && cmd.ProcessState.ExitCode() == 1 {
+			return nil
+		}
+
+		if err == nil {
+			fmt.Fprintf(os.Stdout, "[%s] manifests are up to date\n", m.options.Name)
+		}
+
+		return err
+	})
+}
+
+func (m *manifestsExecutor) Apply() error {
+	return task.RunPhase(m.options, "apply", func(ctx context.Context) error {
+		return m.executeKubectl(ctx, "apply", "--server-side", "--field-manager", fieldManager, "-f", filepath.Join(m.options.Path, applyFile))
+	})
+}
+
+func (m *manifestsExecutor) Destroy() error {
+	return task.RunPhase(m.options, "destroy", func(ctx context.Context) error {
+		return m.executeKubectl(ctx, "delete", "--ignore-not-found", "-f", filepath.Join(m.options.Path, destroyFile))
+	})
+}
+
+func (m *manifestsExecutor) Finalize() error {
+	// rendered manifests may hold secrets
+	return os.RemoveAll(m.options.Path)
+}
+
+func (m *manifestsExecutor) String() string {
+	return "manifests"
+}
+
+func (m *manifestsExecutor) Inputs() map[string]interface{} {
+	inputs := map[string]interface{}{
+		"namespace": m.namespace(),
+		"values":    m.values(),
+	}
+
+	// the templates are inputs too so editing one is never resumed
+	files, _ := m.files()
+
+	templates := map[string]string{}
+
+	for _, f := range files {
+		bs, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+
+		templates[filepath.Base(f)] = string(bs)
+	}
+
+	inputs["templates"] = templates
+
+	return inputs
+}
+
+// files lists the yaml files of the source directory in name order.
+func (m *manifestsExecutor) files() ([]string, error) {
+	entries, err := os.ReadDir(m.options.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifests: %v", err)
+	}
+
+	files := []string{}
+
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+
+		if e.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+
+		files = append(files, filepath.Join(m.options.Source, e.Name()))
+	}
+
+	return files, nil
+}
+
+// render templates each file with the values and splits it into objects.
+func (m *manifestsExecutor) render(files []string) ([]object, error) {
+	objects := []object{}
+
+	for _, f := range files {
+		tmpl, err := template.New(filepath.Base(f)).Option("missingkey=error").ParseFiles(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", f, err)
+		}
+
+		buf := &bytes.Buffer{}
+
+		if err := tmpl.Execute(buf, m.values()); err != nil {
+			return nil, fmt.Errorf("failed to template %s: %v", f, err)
+		}
+
+		for _, doc := range separatorRegexp.Split(buf.String(), -1) {
+			if len(strings.TrimSpace(doc)) == 0 {
+				continue
+			}
+
+			meta := struct {
+				Kind     string `yaml:"kind"`
+				Metadata struct {
+					Name string `yaml:"name"`
+				} `yaml:"metadata"`
+			}{}
+
+			if err := yaml.Unmarshal([]byte(doc), &meta); err != nil {
+				return nil, fmt.Errorf("failed to parse a manifest in %s: %v", f, err)
+			}
+
+			// a file can hold nothing but comments
+			if len(meta.Kind) == 0 && len(meta.Metadata.Name) == 0 {
+				continue
+			}
+
+			if len(meta.Kind) == 0 || len(meta.Metadata.Name) == 0 {
+				return nil, fmt.Errorf("a manifest in %s has no kind or name", f)
+			}
+
+			objects = append(objects, object{
+				Kind:     meta.Kind,
+				Name:     meta.Metadata.Name,
+				Manifest: strings.Trim(doc, "\n"),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+func (m *manifestsExecutor) writeObjects(name string, objects []object) error {
+	docs := []string{}
+
+	for _, o := range objects {
+		docs = append(docs, o.Manifest)
+	}
+
+	return os.WriteFile(filepath.Join(m.options.Path, name), []byte(strings.Join(docs, "\n---\n")+"\n"), 0o600)
+}
+
+func (m *manifestsExecutor) executeKubectl(ctx context.Context, args ...string) error {
+	return task.ExecuteCommand(m.command(ctx, args...), m.options.Name, secret.Values())
+}
+
+// command points kubectl at the same cluster the terraform components use.
+func (m *manifestsExecutor) command(ctx context.Context, args ...string) *exec.Cmd {
+	args = append(args, "--namespace", m.namespace())
+
+	if path := m.options.EnvVars["KUBE_CONFIG_PATH"]; len(path) != 0 {
+		args = append(args, "--kubeconfig", path)
+	}
+
+	if context := m.options.EnvVars["KUBE_CTX"]; len(context) != 0 {
+		args = append(args, "--context", context)
+	}
+
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Dir = m.options.Path
+	cmd.Env = os.Environ()
+
+	for k, v := range m.options.EnvVars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return cmd
+}
+
+func (m *manifestsExecutor) namespace() string {
+	namespace, _ := m.options.Context.Value("manifests_namespace_key").(string)
+	if len(namespace) == 0 {
+		return "default"
+	}
+
+	return namespace
+}
+
+func (m *manifestsExecutor) values() map[string]interface{} {
+	values := map[string]interface{}{}
+	if v, ok := m.options.Context.Value("manifests_values_key").(map[string]interface{}); ok {
+		values = v
+	}
+
+	return values
+}
+
+func NewTask(opts ...task.TaskOption) task.Task {
+	options := task.NewTaskOptions(opts...)
+
+	m := &manifestsExecutor{
+		options: options,
+	}
+
+	return m
+}
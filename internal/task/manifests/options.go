@@ -0,0 +1,21 @@
+package manifests
+
+import (
+	"context"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// ManifestsWithNamespace sets the namespace of manifests that do not name one.
+func ManifestsWithNamespace(namespace string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "manifests_namespace_key", namespace)
+	}
+}
+
+// ManifestsWithValues sets the values the manifests are templated with.
+func ManifestsWithValues(values map[string]interface{}) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "manifests_values_key", values)
+	}
+}
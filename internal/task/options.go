@@ -1,15 +1,32 @@
 package task
 
-import "context"
+import (
+	"context"
+	"time"
+)
+
+type Role string
+
+const (
+	// RoleResource tasks manage resources: they are planned, applied and destroyed.
+	RoleResource Role = ""
+	// RoleCredentials tasks only produce credentials other tasks need, e.g.
+	// a kubeconfig. They run first in every phase and own no resources.
+	RoleCredentials Role = "credentials"
+)
 
 type TaskOption func(o *TaskOptions)
 
 type TaskOptions struct {
-	Name    string
-	Source  string
-	Path    string
-	EnvVars map[string]string
-	Context context.Context
+	Name         string
+	Source       string
+	Path         string
+	EnvVars      map[string]string
+	Timeout      time.Duration
+	Retry        RetryPolicy
+	Dependencies []string
+	Role         Role
+	Context      context.Context
 }
 
 func TaskWithName(n string) TaskOption {
@@ -36,9 +53,45 @@ func TaskWithEnvVars(ev map[string]string) TaskOption {
 	}
 }
 
+// TaskWithTimeout bounds each lifecycle call of the task. Zero means no bound.
+func TaskWithTimeout(d time.Duration) TaskOption {
+	return func(o *TaskOptions) {
+		o.Timeout = d
+	}
+}
+
+// TaskWithRetry sets the policy used to retry failed lifecycle calls of the task.
+func TaskWithRetry(r RetryPolicy) TaskOption {
+	return func(o *TaskOptions) {
+		o.Retry = r
+	}
+}
+
+// TaskWithDependencies names the tasks that must succeed before this one runs.
+func TaskWithDependencies(names ...string) TaskOption {
+	return func(o *TaskOptions) {
+		o.Dependencies = names
+	}
+}
+
+func TaskWithRole(r Role) TaskOption {
+	return func(o *TaskOptions) {
+		o.Role = r
+	}
+}
+
+// baseContext is the parent of every task's context.
+var baseContext = context.Background()
+
+// SetBaseContext makes ctx the parent of the context of every task created
+// from then on, so cancelling it, e.g. on an interrupt, stops them all.
+func SetBaseContext(ctx context.Context) {
+	baseContext = ctx
+}
+
 func NewTaskOptions(opts ...TaskOption) TaskOptions {
 	options := TaskOptions{
-		Context: context.Background(),
+		Context: baseContext,
 	}
 
 	for _, fn := range opts {
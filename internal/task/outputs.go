@@ -0,0 +1,41 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var envNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Outputter is implemented by tasks that produce values later tasks can use,
+// e.g. terraform outputs.
+type Outputter interface {
+	Outputs() (map[string]interface{}, error)
+}
+
+// OutputEnv renders outputs as env vars named TF_OUTPUT_<NAME>. Strings are
+// passed as they are and everything else as json.
+func OutputEnv(outputs map[string]interface{}) map[string]string {
+	env := map[string]string{}
+
+	for k, v := range outputs {
+		name := "TF_OUTPUT_" + strings.ToUpper(envNameRegexp.ReplaceAllString(k, "_"))
+
+		if s, ok := v.(string); ok {
+			env[name] = s
+			continue
+		}
+
+		bs, err := json.Marshal(v)
+		if err != nil {
+			env[name] = fmt.Sprint(v)
+			continue
+		}
+
+		env[name] = string(bs)
+	}
+
+	return env
+}
@@ -0,0 +1,169 @@
+package ready
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type objectMeta struct {
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+}
+
+type workloadList struct {
+	Items []struct {
+		Kind     string     `json:"kind"`
+		Metadata objectMeta `json:"metadata"`
+		Spec     struct {
+			Replicas *int32 `json:"replicas"`
+		} `json:"spec"`
+		Status struct {
+			ObservedGeneration int64  `json:"observedGeneration"`
+			Replicas           int32  `json:"replicas"`
+			UpdatedReplicas    int32  `json:"updatedReplicas"`
+			ReadyReplicas      int32  `json:"readyReplicas"`
+			AvailableReplicas  int32  `json:"availableReplicas"`
+			CurrentRevision    string `json:"currentRevision"`
+			UpdateRevision     string `json:"updateRevision"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type podList struct {
+	Items []struct {
+		Metadata objectMeta `json:"metadata"`
+		Status   struct {
+			Phase      string `json:"phase"`
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+			ContainerStatuses []struct {
+				Name         string `json:"name"`
+				RestartCount int32  `json:"restartCount"`
+				State        struct {
+					Waiting *struct {
+						Reason string `json:"reason"`
+					} `json:"waiting"`
+					Terminated *struct {
+						Reason string `json:"reason"`
+					} `json:"terminated"`
+				} `json:"state"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type eventList struct {
+	Items []struct {
+		Type          string    `json:"type"`
+		Reason        string    `json:"reason"`
+		Message       string    `json:"message"`
+		LastTimestamp time.Time `json:"lastTimestamp"`
+		EventTime     time.Time `json:"eventTime"`
+	} `json:"items"`
+}
+
+// unready describes every workload that has not fully rolled out.
+func (l workloadList) unready() []string {
+	unready := []string{}
+
+	for _, w := range l.Items {
+		want := int32(1)
+		if w.Spec.Replicas != nil {
+			want = *w.Spec.Replicas
+		}
+
+		s := w.Status
+
+		name := fmt.Sprintf("%s/%s", strings.ToLower(w.Kind), w.Metadata.Name)
+
+		switch {
+		case s.ObservedGeneration < w.Metadata.Generation:
+			unready = append(unready, fmt.Sprintf("%s has not observed its latest spec", name))
+		case s.UpdatedReplicas < want:
+			unready = append(unready, fmt.Sprintf("%s has %d of %d replicas updated", name, s.UpdatedReplicas, want))
+		case w.Kind == "Deployment" && s.Replicas > s.UpdatedReplicas:
+			unready = append(unready, fmt.Sprintf("%s has %d old replicas left", name, s.Replicas-s.UpdatedReplicas))
+		case w.Kind == "Deployment" && s.AvailableReplicas < want:
+			unready = append(unready, fmt.Sprintf("%s has %d of %d replicas available", name, s.AvailableReplicas, want))
+		case w.Kind == "StatefulSet" && s.ReadyReplicas < want:
+			unready = append(unready, fmt.Sprintf("%s has %d of %d replicas ready", name, s.ReadyReplicas, want))
+		case w.Kind == "StatefulSet" && len(s.UpdateRevision) != 0 && s.CurrentRevision != s.UpdateRevision:
+			unready = append(unready, fmt.Sprintf("%s is still rolling out revision %s", name, s.UpdateRevision))
+		}
+	}
+
+	sort.Strings(unready)
+
+	return unready
+}
+
+// unready says why each pod that is not ready is not.
+func (l podList) unready() map[string]string {
+	unready := map[string]string{}
+
+	for _, p := range l.Items {
+		ready := false
+
+		for _, c := range p.Status.Conditions {
+			if c.Type == "Ready" && c.Status == "True" {
+				ready = true
+			}
+		}
+
+		if ready || p.Status.Phase == "Succeeded" {
+			continue
+		}
+
+		reasons := []string{p.Status.Phase}
+
+		for _, c := range p.Status.ContainerStatuses {
+			switch {
+			case c.State.Waiting != nil && len(c.State.Waiting.Reason) != 0:
+				reasons = append(reasons, fmt.Sprintf("%s %s", c.Name, c.State.Waiting.Reason))
+			case c.State.Terminated != nil && len(c.State.Terminated.Reason) != 0:
+				reasons = append(reasons, fmt.Sprintf("%s %s", c.Name, c.State.Terminated.Reason))
+			}
+
+			if c.RestartCount > 0 {
+				reasons = append(reasons, fmt.Sprintf("%s restarted %d times", c.Name, c.RestartCount))
+			}
+		}
+
+		unready[p.Metadata.Name] = strings.Join(reasons, ", ")
+	}
+
+	return unready
+}
+
+// last returns the n most recent events, oldest first.
+func (l eventList) last(n int) []string {
+	items := l.Items
+
+	at := func(i int) time.Time {
+		if !items[i].LastTimestamp.IsZero() {
+			return items[i].LastTimestamp
+		}
+
+		return items[i].EventTime
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return at(i).Before(at(j))
+	})
+
+	if len(items) > n {
+		items = items[len(items)-n:]
+	}
+
+	events := []string{}
+
+	for _, e := range items {
+		events = append(events, fmt.Sprintf("%s %s: %s", e.Type, e.Reason, strings.TrimSpace(e.Message)))
+	}
+
+	return events
+}
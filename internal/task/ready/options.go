@@ -0,0 +1,36 @@
+package ready
+
+import (
+	"context"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// ReadyWithNamespace sets the namespace whose workloads must roll out.
+func ReadyWithNamespace(namespace string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "ready_namespace_key", namespace)
+	}
+}
+
+// ReadyWithSelector limits the workloads waited for to those with the labels.
+func ReadyWithSelector(selector string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "ready_selector_key", selector)
+	}
+}
+
+// ReadyWithURL sets a health URL that must answer with a 2xx as well.
+func ReadyWithURL(url string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "ready_url_key", url)
+	}
+}
+
+// ReadyWithRollback sets a func that is handed the error of a failed wait and
+// returns the error to report after rolling back what was applied.
+func ReadyWithRollback(fn func(err error) error) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "ready_rollback_key", fn)
+	}
+}
@@ -0,0 +1,283 @@
+package ready
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+const (
+	pollInterval = 5 * time.Second
+
+	summaryTimeout = 30 * time.Second
+
+	eventsPerPod = 3
+)
+
+var errNoWorkloads = errors.New("no workloads match")
+
+// readinessGate waits for what a component deployed to be ready after it
+// was applied. It deploys nothing itself.
+type readinessGate struct {
+	options task.TaskOptions
+}
+
+func (g *readinessGate) Options() task.TaskOptions {
+	return g.options
+}
+
+func (g *readinessGate) Validate() error {
+	return task.RunPhase(g.options, "validate", func(ctx context.Context) error {
+		if len(g.url()) == 0 {
+			return nil
+		}
+
+		if _, err := url.ParseRequestURI(g.url()); err != nil {
+			return fmt.Errorf("invalid ready url %s: %v", g.url(), err)
+		}
+
+		return nil
+	})
+}
+
+func (g *readinessGate) Plan() error {
+	fmt.Fprintf(os.Stdout, "[%s] will wait up to %s for %s\n", g.options.Name, g.options.Timeout, g.target())
+	return nil
+}
+
+func (g *readinessGate) Apply() error {
+	err := task.RunPhase(g.options, "ready", func(ctx context.Context) error {
+		if _, err := exec.LookPath("kubectl"); err != nil {
+			return fmt.Errorf("kubectl is required to wait for readiness: %v", err)
+		}
+
+		fmt.Fprintf(os.Stdout, "[%s] waiting for %s\n", g.options.Name, g.target())
+
+		var reasons []string
+
+		for {
+			var err error
+
+			reasons, err = g.check(ctx)
+			if err == nil && len(reasons) == 0 {
+				fmt.Fprintf(os.Stdout, "[%s] ready\n", g.options.Name)
+				return nil
+			}
+
+			// waiting will not make a wrong namespace or selector match
+			if errors.Is(err, errNoWorkloads) {
+				return fmt.Errorf("no %s", g.target())
+			}
+
+			if err != nil {
+				reasons = []string{err.Error()}
+			}
+
+			select {
+			case <-ctx.Done():
+				g.summarize(reasons)
+				return ctx.Err()
+			case <-time.After(pollInterval):
+			}
+		}
+	})
+
+	// only a rollout that never got ready is rolled back, and outside the
+	// phase since its time is up
+	var timeout *task.TimeoutError
+
+	if fn, ok := g.options.Context.Value("ready_rollback_key").(func(error) error); ok && errors.As(err, &timeout) {
+		return fn(err)
+	}
+
+	return err
+}
+
+func (g *readinessGate) Destroy() error {
+	return nil
+}
+
+func (g *readinessGate) Finalize() error {
+	return nil
+}
+
+func (g *readinessGate) String() string {
+	return "ready"
+}
+
+func (g *readinessGate) Inputs() map[string]interface{} {
+	return map[string]interface{}{
+		"namespace": g.namespace(),
+		"selector":  g.selector(),
+		"url":       g.url(),
+	}
+}
+
+func (g *readinessGate) target() string {
+	target := fmt.Sprintf("deployments and statefulsets in namespace %s", g.namespace())
+
+	if len(g.selector()) != 0 {
+		target = fmt.Sprintf("%s with labels %s", target, g.selector())
+	}
+
+	if len(g.url()) != 0 {
+		target = fmt.Sprintf("%s and %s", target, g.url())
+	}
+
+	return target
+}
+
+// check returns why things are not ready yet, nothing once they are.
+func (g *readinessGate) check(ctx context.Context) ([]string, error) {
+	workloads := workloadList{}
+
+	if err := g.kubectl(ctx, &workloads, "get", "deployments,statefulsets"); err != nil {
+		return nil, err
+	}
+
+	if len(workloads.Items) == 0 {
+		return nil, errNoWorkloads
+	}
+
+	reasons := workloads.unready()
+
+	if len(reasons) != 0 || len(g.url()) == 0 {
+		return reasons, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return []string{fmt.Sprintf("%s is not answering: %v", g.url(), err)}, nil
+	}
+
+	rsp.Body.Close()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return []string{fmt.Sprintf("%s answered %d", g.url(), rsp.StatusCode)}, nil
+	}
+
+	return nil, nil
+}
+
+// summarize reports what was still not ready along with the pods that are
+// not and their last events.
+func (g *readinessGate) summarize(reasons []string) {
+	for _, reason := range reasons {
+		fmt.Fprintf(os.Stdout, "[%s] not ready: %s\n", g.options.Name, reason)
+	}
+
+	// the phase's context is done by now
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+
+	pods := podList{}
+
+	if err := g.kubectl(ctx, &pods, "get", "pods"); err != nil {
+		fmt.Fprintf(os.Stdout, "[%s] failed to list pods: %v\n", g.options.Name, err)
+		return
+	}
+
+	unready := pods.unready()
+
+	names := []string{}
+	for name := range unready {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stdout, "[%s] pod %s: %s\n", g.options.Name, name, unready[name])
+
+		events := eventList{}
+
+		if err := g.kubectl(ctx, &events, "get", "events", "--field-selector", fmt.Sprintf("involvedObject.name=%s", name)); err != nil {
+			continue
+		}
+
+		for _, e := range events.last(eventsPerPod) {
+			fmt.Fprintf(os.Stdout, "[%s]   %s\n", g.options.Name, e)
+		}
+	}
+}
+
+// kubectl runs kubectl against the component's cluster and namespace and
+// decodes its json output into v.
+func (g *readinessGate) kubectl(ctx context.Context, v interface{}, args ...string) error {
+	args = append(args, "--namespace", g.namespace(), "--output", "json")
+
+	// events are not labelled like the workloads
+	if len(g.selector()) != 0 && args[1] != "events" {
+		args = append(args, "--selector", g.selector())
+	}
+
+	if path := g.options.EnvVars["KUBE_CONFIG_PATH"]; len(path) != 0 {
+		args = append(args, "--kubeconfig", path)
+	}
+
+	if context := g.options.EnvVars["KUBE_CTX"]; len(context) != 0 {
+		args = append(args, "--context", context)
+	}
+
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Env = os.Environ()
+
+	for k, v := range g.options.EnvVars {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) != 0 {
+			return fmt.Errorf("kubectl %s failed: %s", strings.Join(args[:2], " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+
+		return fmt.Errorf("kubectl %s failed: %v", strings.Join(args[:2], " "), err)
+	}
+
+	return json.Unmarshal(out, v)
+}
+
+func (g *readinessGate) namespace() string {
+	namespace, _ := g.options.Context.Value("ready_namespace_key").(string)
+	if len(namespace) == 0 {
+		return "default"
+	}
+
+	return namespace
+}
+
+func (g *readinessGate) selector() string {
+	selector, _ := g.options.Context.Value("ready_selector_key").(string)
+	return selector
+}
+
+func (g *readinessGate) url() string {
+	u, _ := g.options.Context.Value("ready_url_key").(string)
+	return u
+}
+
+func NewTask(opts ...task.TaskOption) task.Task {
+	options := task.NewTaskOptions(opts...)
+
+	g := &readinessGate{
+		options: options,
+	}
+
+	return g
+}
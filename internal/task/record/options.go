@@ -0,0 +1,30 @@
+package record
+
+import (
+	"context"
+
+	"github.com/w-h-a/cli/internal/history"
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// RecordWithKey sets where in the history store the deployment is recorded.
+func RecordWithKey(key string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "record_key_key", key)
+	}
+}
+
+// RecordWithEntry sets what is recorded. The module, user and time are
+// filled in when the entry is written.
+func RecordWithEntry(e history.Entry) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "record_entry_key", e)
+	}
+}
+
+// RecordWithModule sets the task whose revision is recorded as the module.
+func RecordWithModule(t task.Task) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "record_module_key", t)
+	}
+}
@@ -0,0 +1,109 @@
+package record
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/w-h-a/cli/internal/history"
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// recorder writes a successful deployment to the history store.
+type recorder struct {
+	options task.TaskOptions
+}
+
+func (r *recorder) Options() task.TaskOptions {
+	return r.options
+}
+
+func (r *recorder) Validate() error {
+	return task.RunPhase(r.options, "validate", func(ctx context.Context) error {
+		if len(r.key()) == 0 {
+			return fmt.Errorf("no history key given for %s", r.options.Name)
+		}
+
+		return nil
+	})
+}
+
+func (r *recorder) Plan() error {
+	e := r.entry()
+
+	fmt.Fprintf(os.Stdout, "[%s] will record %s %s in %s\n", r.options.Name, e.Service, e.Version, r.key())
+
+	return nil
+}
+
+func (r *recorder) Apply() error {
+	return task.RunPhase(r.options, "apply", func(ctx context.Context) error {
+		e := r.entry()
+
+		if m, ok := r.options.Context.Value("record_module_key").(task.Task); ok {
+			if rev, ok := m.(task.Revisioner); ok {
+				e.Module = rev.Revision()
+			}
+		}
+
+		e.User = deployer()
+		e.Timestamp = time.Now().UTC()
+
+		if err := history.Append(ctx, r.key(), e); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "[%s] recorded %s %s\n", r.options.Name, e.Service, e.Version)
+
+		return nil
+	})
+}
+
+func (r *recorder) Destroy() error {
+	return nil
+}
+
+func (r *recorder) Finalize() error {
+	return nil
+}
+
+func (r *recorder) String() string {
+	return "record"
+}
+
+func (r *recorder) Inputs() map[string]interface{} {
+	return map[string]interface{}{
+		"key":   r.key(),
+		"entry": r.entry(),
+	}
+}
+
+func (r *recorder) key() string {
+	key, _ := r.options.Context.Value("record_key_key").(string)
+	return key
+}
+
+func (r *recorder) entry() history.Entry {
+	e, _ := r.options.Context.Value("record_entry_key").(history.Entry)
+	return e
+}
+
+func deployer() string {
+	if u, err := user.Current(); err == nil && len(u.Username) != 0 {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+func NewTask(opts ...task.TaskOption) task.Task {
+	options := task.NewTaskOptions(opts...)
+
+	r := &recorder{
+		options: options,
+	}
+
+	return r
+}
@@ -0,0 +1,61 @@
+package task
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	Patterns    []string
+}
+
+// Retryable reports whether err, or the output captured with it, matches
+// one of the policy's patterns.
+func (r RetryPolicy) Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	text := err.Error()
+
+	var outErr *OutputError
+	if errors.As(err, &outErr) {
+		text += "\n" + outErr.Output
+	}
+
+	for _, pattern := range r.Patterns {
+		if len(pattern) != 0 && strings.Contains(text, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delay returns how long to wait after the given failed attempt, doubling
+// the backoff each time.
+func (r RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	return r.Backoff * time.Duration(1<<(attempt-1))
+}
+
+// OutputError carries the output of a failed command alongside its error
+// so callers can inspect what the command said without printing it twice.
+type OutputError struct {
+	Err    error
+	Output string
+}
+
+func (e *OutputError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
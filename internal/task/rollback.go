@@ -0,0 +1,20 @@
+package task
+
+import "fmt"
+
+// RolledBackError reports a failed rollout of a task that was rolled back to
+// the version it ran before.
+type RolledBackError struct {
+	Err  error
+	Task string
+	From string
+	To   string
+}
+
+func (e *RolledBackError) Error() string {
+	return fmt.Sprintf("%v, rolled %s back from %s to %s", e.Err, e.Task, e.From, e.To)
+}
+
+func (e *RolledBackError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,60 @@
+package rollout
+
+import (
+	"context"
+	"time"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// ReleaseFunc builds the task that deploys a release of version with the
+// given percentage of the traffic. The candidate is the release deployed
+// alongside the stable one.
+type ReleaseFunc func(candidate bool, version string, weight int) task.Task
+
+// GateFunc builds the readiness gate of the release of version deployed by
+// the given task, nil if it has none. The gate of the candidate must wait
+// for the candidate's workloads, not the stable release's.
+type GateFunc func(candidate bool, version string, release task.Task) task.Task
+
+// RolloutWithStrategy sets the strategy, canary or bluegreen.
+func RolloutWithStrategy(strategy string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "rollout_strategy_key", strategy)
+	}
+}
+
+// RolloutWithSteps sets the percentages of traffic a canary gets in turn.
+func RolloutWithSteps(steps []int) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "rollout_steps_key", steps)
+	}
+}
+
+// RolloutWithPause sets how long to wait between steps.
+func RolloutWithPause(pause time.Duration) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "rollout_pause_key", pause)
+	}
+}
+
+// RolloutWithVersions sets the version to roll out and a func that finds
+// the version running now, empty if there is none.
+func RolloutWithVersions(version string, running func() string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "rollout_version_key", version)
+		o.Context = context.WithValue(o.Context, "rollout_running_key", running)
+	}
+}
+
+func RolloutWithRelease(fn ReleaseFunc) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "rollout_release_key", fn)
+	}
+}
+
+func RolloutWithGate(fn GateFunc) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "rollout_gate_key", fn)
+	}
+}
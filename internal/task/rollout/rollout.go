@@ -0,0 +1,310 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+const (
+	Canary    = "canary"
+	BlueGreen = "bluegreen"
+)
+
+var (
+	defaultCanarySteps = []int{10, 50, 100}
+
+	// blue/green brings green up without traffic and then switches
+	blueGreenSteps = []int{0, 100}
+)
+
+// rolloutExecutor deploys a new version next to the running one, shifts the
+// traffic over step by step and then promotes it. It drives the tasks of the
+// releases rather than deploying anything itself.
+type rolloutExecutor struct {
+	options  task.TaskOptions
+	revision string
+}
+
+func (r *rolloutExecutor) Options() task.TaskOptions {
+	return r.options
+}
+
+func (r *rolloutExecutor) Validate() error {
+	return task.RunPhase(r.options, "validate", func(ctx context.Context) error {
+		switch r.strategy() {
+		case Canary, BlueGreen:
+		default:
+			return fmt.Errorf("strategy must be %s or %s, not %s", Canary, BlueGreen, r.strategy())
+		}
+
+		if r.release() == nil {
+			return fmt.Errorf("no release given for %s", r.options.Name)
+		}
+
+		steps := r.steps()
+
+		for i, w := range steps {
+			if w < 0 || w > 100 || (i > 0 && w <= steps[i-1]) {
+				return fmt.Errorf("rollout steps must rise from 0 to 100, not %v", steps)
+			}
+		}
+
+		if len(steps) == 0 || steps[len(steps)-1] != 100 {
+			return fmt.Errorf("rollout steps must end with 100, not %v", steps)
+		}
+
+		// the stable release must be sound before anything is shifted
+		stable := r.release()(false, r.version(), 100)
+
+		defer stable.Finalize()
+
+		return stable.Validate()
+	})
+}
+
+func (r *rolloutExecutor) Plan() error {
+	return task.RunPhase(r.options, "plan", func(ctx context.Context) error {
+		fmt.Fprintf(os.Stdout, "[%s] will roll out %s %s with traffic steps %v\n", r.options.Name, r.strategy(), r.version(), r.steps())
+
+		stable := r.release()(false, r.version(), 100)
+
+		defer stable.Finalize()
+
+		if err := stable.Validate(); err != nil {
+			return err
+		}
+
+		return stable.Plan()
+	})
+}
+
+func (r *rolloutExecutor) Apply() error {
+	return task.RunPhase(r.options, "apply", r.apply)
+}
+
+// apply takes the traffic over in steps. The releases run their own tasks
+// with their own timeouts, ctx bounds the rollout as a whole.
+func (r *rolloutExecutor) apply(ctx context.Context) error {
+	running := ""
+	if fn, ok := r.options.Context.Value("rollout_running_key").(func() string); ok {
+		running = fn()
+	}
+
+	if len(running) == 0 || running == r.version() {
+		fmt.Fprintf(os.Stdout, "[%s] no other version is running, deploying %s in place\n", r.options.Name, r.version())
+		return r.deploy(false, r.version(), 100)
+	}
+
+	steps := r.steps()
+
+	for i, w := range steps {
+		fmt.Fprintf(os.Stdout, "[%s] shifting %d%% of the traffic from %s to %s\n", r.options.Name, w, running, r.version())
+
+		if err := r.deploy(true, r.version(), w); err != nil {
+			return r.abort(running, err)
+		}
+
+		if err := r.deploy(false, running, 100-w); err != nil {
+			return r.abort(running, err)
+		}
+
+		if i < len(steps)-1 && r.pause() > 0 {
+			fmt.Fprintf(os.Stdout, "[%s] pausing for %s\n", r.options.Name, r.pause())
+
+			select {
+			case <-ctx.Done():
+				return r.abort(running, ctx.Err())
+			case <-time.After(r.pause()):
+			}
+		}
+	}
+
+	// the candidate serves everything, so the stable release can take the new version
+	fmt.Fprintf(os.Stdout, "[%s] promoting %s\n", r.options.Name, r.version())
+
+	if err := r.deploy(false, r.version(), 100); err != nil {
+		return r.abort(running, err)
+	}
+
+	if err := r.teardown(); err != nil {
+		return fmt.Errorf("%s is live but its candidate release is left over: %w", r.version(), err)
+	}
+
+	fmt.Fprintf(os.Stdout, "[%s] rolled out %s\n", r.options.Name, r.version())
+
+	return nil
+}
+
+func (r *rolloutExecutor) Destroy() error {
+	return task.RunPhase(r.options, "destroy", func(ctx context.Context) error {
+		stable := r.release()(false, r.version(), 100)
+
+		defer stable.Finalize()
+
+		if err := stable.Validate(); err != nil {
+			return err
+		}
+
+		return stable.Destroy()
+	})
+}
+
+func (r *rolloutExecutor) Finalize() error {
+	return nil
+}
+
+func (r *rolloutExecutor) String() string {
+	return "rollout"
+}
+
+// Revision is the revision of the module the new version was promoted with.
+func (r *rolloutExecutor) Revision() string {
+	return r.revision
+}
+
+// Outputs reads the outputs of the stable release.
+func (r *rolloutExecutor) Outputs() (map[string]interface{}, error) {
+	stable := r.release()(false, r.version(), 100)
+
+	defer stable.Finalize()
+
+	o, ok := stable.(task.Outputter)
+	if !ok {
+		return map[string]interface{}{}, nil
+	}
+
+	if err := stable.Validate(); err != nil {
+		return nil, err
+	}
+
+	return o.Outputs()
+}
+
+func (r *rolloutExecutor) Inputs() map[string]interface{} {
+	inputs := map[string]interface{}{
+		"strategy": r.strategy(),
+		"steps":    r.steps(),
+		"version":  r.version(),
+	}
+
+	// a changed port, image or secret must be rolled out too
+	if fn := r.release(); fn != nil {
+		if in, ok := fn(false, r.version(), 100).(task.Inputter); ok {
+			inputs["release"] = in.Inputs()
+		}
+	}
+
+	return inputs
+}
+
+// deploy applies a release and waits for it to be ready.
+func (r *rolloutExecutor) deploy(candidate bool, version string, weight int) error {
+	t := r.release()(candidate, version, weight)
+
+	defer t.Finalize()
+
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	if err := t.Apply(); err != nil {
+		return err
+	}
+
+	if rev, ok := t.(task.Revisioner); ok && !candidate && version == r.version() {
+		r.revision = rev.Revision()
+	}
+
+	fn, ok := r.options.Context.Value("rollout_gate_key").(GateFunc)
+	if !ok {
+		return nil
+	}
+
+	gate := fn(candidate, version, t)
+	if gate == nil {
+		return nil
+	}
+
+	defer gate.Finalize()
+
+	if err := gate.Validate(); err != nil {
+		return err
+	}
+
+	return gate.Apply()
+}
+
+// abort gives the running version all the traffic back and removes the
+// candidate.
+func (r *rolloutExecutor) abort(running string, err error) error {
+	fmt.Fprintf(os.Stderr, "[%s] %s rollout of %s failed, restoring %s: %v\n", r.options.Name, r.strategy(), r.version(), running, err)
+
+	if restoreErr := r.deploy(false, running, 100); restoreErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to restore %s: %w", running, restoreErr))
+	}
+
+	if teardownErr := r.teardown(); teardownErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to tear down the candidate release of %s: %w", r.version(), teardownErr))
+	}
+
+	return fmt.Errorf("%s rollout of %s failed, %s keeps all the traffic: %w", r.strategy(), r.version(), running, err)
+}
+
+func (r *rolloutExecutor) teardown() error {
+	t := r.release()(true, r.version(), 0)
+
+	defer t.Finalize()
+
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	return t.Destroy()
+}
+
+func (r *rolloutExecutor) strategy() string {
+	strategy, _ := r.options.Context.Value("rollout_strategy_key").(string)
+	return strategy
+}
+
+func (r *rolloutExecutor) steps() []int {
+	if r.strategy() == BlueGreen {
+		return blueGreenSteps
+	}
+
+	if steps, ok := r.options.Context.Value("rollout_steps_key").([]int); ok && len(steps) != 0 {
+		return steps
+	}
+
+	return defaultCanarySteps
+}
+
+func (r *rolloutExecutor) pause() time.Duration {
+	pause, _ := r.options.Context.Value("rollout_pause_key").(time.Duration)
+	return pause
+}
+
+func (r *rolloutExecutor) version() string {
+	version, _ := r.options.Context.Value("rollout_version_key").(string)
+	return version
+}
+
+func (r *rolloutExecutor) release() ReleaseFunc {
+	fn, _ := r.options.Context.Value("rollout_release_key").(ReleaseFunc)
+	return fn
+}
+
+func NewTask(opts ...task.TaskOption) task.Task {
+	options := task.NewTaskOptions(opts...)
+
+	r := &rolloutExecutor{
+		options: options,
+	}
+
+	return r
+}
@@ -0,0 +1,172 @@
+package rollout
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w-h-a/cli/internal/task"
+)
+
+// fakeTask records what was done to it in a log shared by all fake tasks.
+type fakeTask struct {
+	name  string
+	log   *[]string
+	apply error
+}
+
+func (f *fakeTask) Options() task.TaskOptions {
+	return task.NewTaskOptions(task.TaskWithName(f.name))
+}
+
+func (f *fakeTask) Validate() error {
+	return nil
+}
+
+func (f *fakeTask) Plan() error {
+	return nil
+}
+
+func (f *fakeTask) Apply() error {
+	*f.log = append(*f.log, "apply "+f.name)
+	return f.apply
+}
+
+func (f *fakeTask) Destroy() error {
+	*f.log = append(*f.log, "destroy "+f.name)
+	return nil
+}
+
+func (f *fakeTask) Finalize() error {
+	return nil
+}
+
+func (f *fakeTask) String() string {
+	return "fake"
+}
+
+func releaseName(candidate bool, version string) string {
+	if candidate {
+		return "candidate " + version
+	}
+
+	return "stable " + version
+}
+
+// newRollout rolls out v2 over v1 with a gate that reports the releases in
+// unready as not ready.
+func newRollout(log *[]string, strategy string, unready []string, opts ...task.TaskOption) task.Task {
+	release := func(candidate bool, version string, weight int) task.Task {
+		return &fakeTask{name: fmt.Sprintf("%s at %d", releaseName(candidate, version), weight), log: log}
+	}
+
+	gate := func(candidate bool, version string, t task.Task) task.Task {
+		name := releaseName(candidate, version)
+
+		g := &fakeTask{name: "gate " + name, log: log}
+
+		for _, u := range unready {
+			if u == name {
+				g.apply = errors.New(name + " is not ready")
+			}
+		}
+
+		return g
+	}
+
+	return NewTask(append([]task.TaskOption{
+		task.TaskWithName("service"),
+		RolloutWithStrategy(strategy),
+		RolloutWithVersions("v2", func() string { return "v1" }),
+		RolloutWithRelease(release),
+		RolloutWithGate(gate),
+	}, opts...)...)
+}
+
+func TestApplyCanary(t *testing.T) {
+	log := []string{}
+
+	if err := newRollout(&log, Canary, nil).Apply(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"apply candidate v2 at 10", "apply gate candidate v2",
+		"apply stable v1 at 90", "apply gate stable v1",
+		"apply candidate v2 at 50", "apply gate candidate v2",
+		"apply stable v1 at 50", "apply gate stable v1",
+		"apply candidate v2 at 100", "apply gate candidate v2",
+		"apply stable v1 at 0", "apply gate stable v1",
+		"apply stable v2 at 100", "apply gate stable v2",
+		"destroy candidate v2 at 0",
+	}
+
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(log, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestApplyUnreadyCandidate(t *testing.T) {
+	for _, test := range []struct {
+		strategy string
+		first    int
+	}{
+		{Canary, 10},
+		{BlueGreen, 0},
+	} {
+		t.Run(test.strategy, func(t *testing.T) {
+			log := []string{}
+
+			// the stable release is ready, so only the candidate's gate can stop the rollout
+			err := newRollout(&log, test.strategy, []string{"candidate v2"}).Apply()
+			if err == nil || !strings.Contains(err.Error(), "candidate v2 is not ready") {
+				t.Fatalf("got %v, want the candidate's gate to fail", err)
+			}
+
+			want := []string{
+				fmt.Sprintf("apply candidate v2 at %d", test.first),
+				"apply gate candidate v2",
+				"apply stable v1 at 100", "apply gate stable v1",
+				"destroy candidate v2 at 0",
+			}
+
+			if !reflect.DeepEqual(log, want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(log, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestApplyPauseTimesOut(t *testing.T) {
+	log := []string{}
+
+	r := newRollout(&log, Canary, nil, task.TaskWithTimeout(50*time.Millisecond), RolloutWithPause(time.Hour))
+
+	start := time.Now()
+
+	err := r.Apply()
+
+	var timeoutErr *task.TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the pause ran for %s past the timeout", elapsed)
+	}
+
+	// the first step was taken, so the running version gets everything back
+	want := []string{
+		"apply candidate v2 at 10", "apply gate candidate v2",
+		"apply stable v1 at 90", "apply gate stable v1",
+		"apply stable v1 at 100", "apply gate stable v1",
+		"destroy candidate v2 at 0",
+	}
+
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(log, "\n"), strings.Join(want, "\n"))
+	}
+}
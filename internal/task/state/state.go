@@ -1,6 +1,7 @@
 package state
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -24,7 +25,7 @@ func (s *stateChecker) Options() task.TaskOptions {
 func (s *stateChecker) Validate() error {
 	stateStore := viper.GetString("state-store")
 
-	if err := s.validateConfig(); err != nil {
+	if err := task.RunPhase(s.options, "validate", s.validateConfig); err != nil {
 		fmt.Fprintf(os.Stdout, "remote state backend in %s is invalid\n", stateStore)
 
 		return err
@@ -51,18 +52,18 @@ func (s *stateChecker) Finalize() error {
 	return nil
 }
 
-func (s *stateChecker) validateConfig() error {
+func (s *stateChecker) validateConfig(ctx context.Context) error {
 	stateStore := viper.GetString("state-store")
 
 	switch stateStore {
 	case "aws":
-		return s.validateAWS()
+		return s.validateAWS(ctx)
 	default:
 		return fmt.Errorf("remote state backend in %s is not supported", stateStore)
 	}
 }
 
-func (s *stateChecker) validateAWS() error {
+func (s *stateChecker) validateAWS(ctx context.Context) error {
 	config := &aws.Config{
 		Region: aws.String(viper.GetString("aws-region")),
 	}
@@ -76,7 +77,8 @@ func (s *stateChecker) validateAWS() error {
 
 	bucket := viper.GetString("aws-s3-bucket")
 
-	if _, err := s3Client.PutObject(
+	if _, err := s3Client.PutObjectWithContext(
+		ctx,
 		&s3.PutObjectInput{
 			Key:    aws.String(s.options.Name),
 			Bucket: aws.String(bucket),
@@ -86,7 +88,8 @@ func (s *stateChecker) validateAWS() error {
 		return fmt.Errorf("failed to put an object into the remote state backend: %v", err)
 	}
 
-	read, err := s3Client.GetObject(
+	read, err := s3Client.GetObjectWithContext(
+		ctx,
 		&s3.GetObjectInput{
 			Key:    aws.String(s.options.Name),
 			Bucket: aws.String(bucket),
@@ -107,7 +110,8 @@ func (s *stateChecker) validateAWS() error {
 		return fmt.Errorf("read back an incorrect value from the remote state backend: want %s, got %s", s.options.Name, string(body))
 	}
 
-	if _, err := s3Client.DeleteObject(
+	if _, err := s3Client.DeleteObjectWithContext(
+		ctx,
 		&s3.DeleteObjectInput{
 			Key:    aws.String(s.options.Name),
 			Bucket: aws.String(bucket),
@@ -12,8 +12,27 @@ func TerraformWithRemoteStates(rs map[string]string) task.TaskOption {
 	}
 }
 
-func TerraformWithVars(vars map[string]string) task.TaskOption {
+// TerraformWithVars sets the variables handed to terraform. Values may be
+// strings, numbers, bools, lists or maps.
+func TerraformWithVars(vars map[string]interface{}) task.TaskOption {
 	return func(o *task.TaskOptions) {
 		o.Context = context.WithValue(o.Context, "tf_vars_key", vars)
 	}
 }
+
+// TerraformWithOutputsBeforeApply sets a func that is handed the module's
+// outputs as they were before the first apply attempt.
+func TerraformWithOutputsBeforeApply(fn func(outputs map[string]interface{})) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "tf_outputs_before_apply_key", fn)
+	}
+}
+
+// TerraformWithRequiredVars sets variables the module must declare. terraform
+// only warns about a var the module does not declare, which would leave the
+// caller's intent silently ignored.
+func TerraformWithRequiredVars(names ...string) task.TaskOption {
+	return func(o *task.TaskOptions) {
+		o.Context = context.WithValue(o.Context, "tf_required_vars_key", names)
+	}
+}
@@ -1,23 +1,27 @@
 package terraform
 
 import (
-	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+	"regexp"
+	"sort"
 	"text/template"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/spf13/viper"
+	"github.com/w-h-a/cli/internal/redact"
+	"github.com/w-h-a/cli/internal/secret"
 	"github.com/w-h-a/cli/internal/task"
 )
 
 const (
+	tfVarsFile = "cli.auto.tfvars.json"
+
 	tfS3BackendTemplate = `terraform {
 		backend "s3" {
 		  bucket         = "{{.StateBucket}}"
@@ -41,8 +45,12 @@ const (
 	  `
 )
 
+var variableRegexp = regexp.MustCompile(`(?m)^\s*variable\s+"([^"]+)"`)
+
 type terraformExecutor struct {
-	options task.TaskOptions
+	options  task.TaskOptions
+	applied  bool
+	revision string
 }
 
 func (t *terraformExecutor) Options() task.TaskOptions {
@@ -50,54 +58,96 @@ func (t *terraformExecutor) Options() task.TaskOptions {
 }
 
 func (t *terraformExecutor) Validate() error {
-	if err := os.MkdirAll(t.options.Path, 0o777); err != nil {
-		return err
-	}
+	return task.RunPhase(t.options, "validate", func(ctx context.Context) error {
+		// start from a clean directory so a retried validate can clone again
+		if err := os.RemoveAll(t.options.Path); err != nil {
+			return err
+		}
 
-	u, err := url.Parse(t.options.Source)
-	if err != nil {
-		return err
-	}
+		if err := os.MkdirAll(t.options.Path, 0o777); err != nil {
+			return err
+		}
 
-	switch u.Scheme {
-	case "http":
-		fallthrough
-	case "https":
-		if err := t.executeGitClone(); err != nil {
+		u, err := url.Parse(t.options.Source)
+		if err != nil {
 			return err
 		}
-	default:
-		return fmt.Errorf("scheme %s is not supported", u.Scheme)
-	}
 
-	if err := t.writeStateFiles(); err != nil {
-		return err
-	}
+		switch u.Scheme {
+		case "http":
+			fallthrough
+		case "https":
+			if err := t.executeGitClone(ctx); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("scheme %s is not supported", u.Scheme)
+		}
 
-	if err := t.executeTerraform(context.Background(), "init"); err != nil {
-		return err
-	}
+		if err := t.checkRequiredVars(); err != nil {
+			return err
+		}
 
-	if err := t.executeTerraform(context.Background(), "validate"); err != nil {
-		return err
-	}
+		if err := t.writeStateFiles(); err != nil {
+			return err
+		}
 
-	return nil
+		if err := t.writeVarsFile(); err != nil {
+			return err
+		}
+
+		if viper.GetBool("verbose") {
+			t.printVars()
+		}
+
+		if err := t.executeTerraform(ctx, "init"); err != nil {
+			return err
+		}
+
+		if err := t.executeTerraform(ctx, "validate"); err != nil {
+			return err
+		}
+
+		return nil
+	})
 }
 
 func (t *terraformExecutor) Plan() error {
-	return t.executeTerraform(context.Background(), "plan")
+	return task.RunPhase(t.options, "plan", func(ctx context.Context) error {
+		return t.executeTerraform(ctx, "plan")
+	})
 }
 
 func (t *terraformExecutor) Apply() error {
-	return t.executeTerraform(context.Background(), "apply", "-auto-approve")
+	// a retried apply may have changed the outputs already
+	if fn, ok := t.options.Context.Value("tf_outputs_before_apply_key").(func(map[string]interface{})); ok && !t.applied {
+		outputs, err := t.Outputs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] warning: %v\n", t.options.Name, err)
+		} else {
+			fn(outputs)
+		}
+	}
+
+	t.applied = true
+
+	return task.RunPhase(t.options, "apply", func(ctx context.Context) error {
+		return t.executeTerraform(ctx, "apply", "-auto-approve")
+	})
 }
 
 func (t *terraformExecutor) Destroy() error {
-	return t.executeTerraform(context.Background(), "destroy", "-auto-approve")
+	return task.RunPhase(t.options, "destroy", func(ctx context.Context) error {
+		return t.executeTerraform(ctx, "destroy", "-auto-approve")
+	})
 }
 
 func (t *terraformExecutor) Finalize() error {
+	// the vars file holds secrets so make sure it goes even if the rest cannot
+	if err := os.Remove(filepath.Join(t.options.Path, tfVarsFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
 	return os.RemoveAll(t.options.Path)
 }
 
@@ -105,6 +155,72 @@ func (t *terraformExecutor) String() string {
 	return "terraform"
 }
 
+// Revision is the commit of the module that was cloned.
+func (t *terraformExecutor) Revision() string {
+	return t.revision
+}
+
+func (t *terraformExecutor) Inputs() map[string]interface{} {
+	inputs := map[string]interface{}{}
+
+	inputs["vars"] = t.vars()
+
+	if rs, ok := t.options.Context.Value("tf_remote_states_key").(map[string]string); ok {
+		inputs["remoteStates"] = rs
+	}
+
+	return inputs
+}
+
+// Outputs reads the outputs from the module's state. Sensitive ones are
+// masked from then on.
+func (t *terraformExecutor) Outputs() (map[string]interface{}, error) {
+	outputs := map[string]interface{}{}
+
+	err := task.RunPhase(t.options, "output", func(ctx context.Context) error {
+		tf := exec.CommandContext(ctx, "terraform", "output", "-json")
+		tf.Dir = t.options.Path
+		tf.Env = os.Environ()
+
+		for k, v := range t.options.EnvVars {
+			tf.Env = append(tf.Env, fmt.Sprintf("%s=%s", k, v))
+		}
+
+		out, err := tf.Output()
+		if err != nil {
+			return fmt.Errorf("failed to read the outputs of %s: %v", t.options.Name, err)
+		}
+
+		raw := map[string]struct {
+			Sensitive bool        `json:"sensitive"`
+			Value     interface{} `json:"value"`
+		}{}
+
+		if err := json.Unmarshal(out, &raw); err != nil {
+			return fmt.Errorf("failed to parse the outputs of %s: %v", t.options.Name, err)
+		}
+
+		for k, o := range raw {
+			if o.Sensitive {
+				if s, ok := o.Value.(string); ok {
+					secret.Add(s)
+				} else if bs, err := json.Marshal(o.Value); err == nil {
+					secret.Add(string(bs))
+				}
+			}
+
+			outputs[k] = o.Value
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return outputs, nil
+}
+
 func (t *terraformExecutor) executeTerraform(ctx context.Context, args ...string) error {
 	// set up terraform command
 	tf := exec.CommandContext(ctx, "terraform", args...)
@@ -115,93 +231,137 @@ func (t *terraformExecutor) executeTerraform(ctx context.Context, args ...string
 		tf.Env = append(tf.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	tfVars := map[string]string{}
-	if v, ok := t.options.Context.Value("tf_vars_key").(map[string]string); ok {
-		tfVars = v
-	}
-	for k, v := range tfVars {
-		tf.Env = append(tf.Env, fmt.Sprintf("TF_VAR_%s=%s", k, v))
-	}
-
-	stdout, err := tf.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("stdoutpipe failed: %v", err)
-	}
-
-	stderr, err := tf.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("stderrpipe failed: %v", err)
-	}
+	// never let terraform echo a secret into the logs
+	return task.ExecuteCommand(tf, t.options.Name, t.secrets())
+}
 
-	// wait so we don't truncate output from terraform
-	ioWait := make(chan struct{})
-	defer func() {
-		// wait for both routines (see below) to finish
-		// so we capture everything
-		<-ioWait
-		<-ioWait
-	}()
-
-	for _, ioPair := range []struct {
-		in  io.ReadCloser
-		out *os.File
-	}{
-		{in: stdout, out: os.Stdout},
-		{in: stderr, out: os.Stderr},
-	} {
-		go func(name string, in io.ReadCloser, out *os.File, done chan<- struct{}) {
-			defer func() {
-				done <- struct{}{}
-			}()
-
-			defer in.Close()
-
-			reader := bufio.NewReader(in)
-
-			for {
-				s, err := reader.ReadString('\n')
-				if err == nil || err == io.EOF {
-					if len(strings.TrimSpace(s)) != 0 {
-						fmt.Fprintf(out, "[%s] %s", name, s)
-					}
-					if err == io.EOF {
-						return
-					}
-				} else {
-					fmt.Fprintf(out, "[%s] error: %s\n", name, err.Error())
-					return
-				}
-			}
+// secrets returns the values of the vars configured as secret along with
+// everything resolved from a secret source.
+func (t *terraformExecutor) secrets() []string {
+	secrets := secret.Values()
 
-		}(t.options.Name, ioPair.in, ioPair.out, ioWait)
-	}
+	tfVars := t.vars()
 
-	if err := tf.Start(); err != nil {
-		return fmt.Errorf("failed to execute terraform: %v", err)
+	for _, name := range viper.GetStringSlice("secret-vars") {
+		if v, ok := tfVars[name].(string); ok {
+			secrets = append(secrets, v)
+		}
 	}
 
-	return tf.Wait()
+	return secrets
 }
 
-func (t *terraformExecutor) executeGitClone() error {
+func (t *terraformExecutor) executeGitClone(ctx context.Context) error {
 	fmt.Fprintf(os.Stdout, "cloning repo %s\n", t.options.Source)
 
-	if _, err := git.PlainClone(
+	repo, err := git.PlainCloneContext(
+		ctx,
 		t.options.Path,
 		false,
 		&git.CloneOptions{
 			URL:      t.options.Source,
 			Progress: os.Stdout,
 		},
-	); err != nil {
+	)
+	if err != nil {
 		return err
 	}
 
+	if head, err := repo.Head(); err == nil {
+		t.revision = head.Hash().String()
+	}
+
 	fmt.Fprintf(os.Stdout, "successfully cloned repo %s\n", t.options.Source)
 
 	return nil
 }
 
+func (t *terraformExecutor) vars() map[string]interface{} {
+	tfVars := map[string]interface{}{}
+	if v, ok := t.options.Context.Value("tf_vars_key").(map[string]interface{}); ok {
+		tfVars = v
+	}
+
+	return tfVars
+}
+
+// checkRequiredVars fails unless the module declares every required var.
+func (t *terraformExecutor) checkRequiredVars() error {
+	required, _ := t.options.Context.Value("tf_required_vars_key").([]string)
+	if len(required) == 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(t.options.Path, "*.tf"))
+	if err != nil {
+		return err
+	}
+
+	declared := map[string]bool{}
+
+	for _, file := range files {
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range variableRegexp.FindAllSubmatch(bs, -1) {
+			declared[string(m[1])] = true
+		}
+	}
+
+	for _, name := range required {
+		if !declared[name] {
+			return fmt.Errorf("module %s does not declare the variable %s that %s needs", t.options.Source, name, t.options.Name)
+		}
+	}
+
+	return nil
+}
+
+// printVars shows the vars terraform will see with every secret masked.
+func (t *terraformExecutor) printVars() {
+	tfVars := t.vars()
+	secrets := t.secrets()
+
+	secretVars := map[string]bool{}
+	for _, name := range viper.GetStringSlice("secret-vars") {
+		secretVars[name] = true
+	}
+
+	keys := []string{}
+	for k := range tfVars {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		value := redact.Mask
+
+		if !secretVars[k] {
+			bs, _ := json.Marshal(tfVars[k])
+			value = redact.String(string(bs), secrets)
+		}
+
+		fmt.Fprintf(os.Stdout, "[%s] var %s = %s\n", t.options.Name, k, value)
+	}
+}
+
+// writeVarsFile renders the vars as json so terraform sees their real types.
+func (t *terraformExecutor) writeVarsFile() error {
+	bs, err := json.MarshalIndent(t.vars(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode vars: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(t.options.Path, tfVarsFile), bs, 0o600); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (t *terraformExecutor) writeStateFiles() error {
 	stateStore := viper.GetString("state-store")
 
@@ -0,0 +1,44 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type TimeoutError struct {
+	Name    string
+	Phase   string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("task %s timed out after %s during %s", e.Name, e.Timeout, e.Phase)
+}
+
+// RunPhase calls fn with a context bounded by the task's timeout and
+// reports a TimeoutError naming the task and phase if the bound is hit.
+func RunPhase(o TaskOptions, phase string, fn func(ctx context.Context) error) error {
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	if o.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(o.Context, o.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(o.Context)
+	}
+
+	defer cancel()
+
+	err := fn(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &TimeoutError{
+			Name:    o.Name,
+			Phase:   phase,
+			Timeout: o.Timeout,
+		}
+	}
+
+	return err
+}